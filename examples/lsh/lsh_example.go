@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mrtkp9993/probdsgo/similarity"
+)
+
+func main() {
+	lsh, err := similarity.NewRandomHyperplaneLSH(4, 4, 8, 42)
+	if err != nil {
+		panic(err)
+	}
+
+	lsh.Insert(1, []float32{1.0, 0.9, 0.0, 0.1})
+	lsh.Insert(2, []float32{0.0, 0.1, 1.0, 0.9})
+	lsh.Insert(3, []float32{0.9, 1.0, 0.1, 0.0})
+
+	query := []float32{1.0, 1.0, 0.0, 0.0}
+
+	candidates, _ := lsh.Query(query, 1)
+	fmt.Println("Candidates (1 probe):", candidates)
+
+	candidates, _ = lsh.Query(query, 4)
+	fmt.Println("Candidates (4 probes):", candidates)
+
+	sim, _ := similarity.CosineSimilarity(query, []float32{1.0, 0.9, 0.0, 0.1})
+	fmt.Println("Cosine similarity with 1:", sim)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mrtkp9993/probdsgo/membership"
+)
+
+func main() {
+	qf, err := membership.NewQuotientFilter(10000, 0.01)
+	if err != nil {
+		panic(err)
+	}
+
+	qf.Insert([]byte("hello"))
+
+	exists, _ := qf.Contains([]byte("hello"))
+	fmt.Println("hello exists?:", exists)
+
+	exists, _ = qf.Contains([]byte("world"))
+	fmt.Println("world exists?:", exists)
+
+	qf.Delete([]byte("hello"))
+	exists, _ = qf.Contains([]byte("hello"))
+	fmt.Println("hello exists after delete?:", exists)
+
+	qf.Insert([]byte("hello"))
+	qf.Insert([]byte("world"))
+
+	qf2, err := membership.NewQuotientFilter(10000, 0.01)
+	if err != nil {
+		panic(err)
+	}
+
+	qf2.Insert([]byte("test"))
+	qf2.Insert([]byte("test2"))
+
+	merged, err := qf.Merge(qf2)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println("Count:", merged.Count())
+	fmt.Println("Load Factor:", merged.LoadFactor())
+
+	resized, err := merged.Resize()
+	if err != nil {
+		panic(err)
+	}
+
+	exists, _ = resized.Contains([]byte("test2"))
+	fmt.Println("test2 exists after resize?:", exists)
+	fmt.Println("Size after resize:", resized.Size())
+}
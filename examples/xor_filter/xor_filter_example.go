@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mrtkp9993/probdsgo/membership"
+)
+
+func main() {
+	keys := [][]byte{
+		[]byte("hello"),
+		[]byte("world"),
+		[]byte("test"),
+	}
+
+	xf, err := membership.NewXor8(keys)
+	if err != nil {
+		panic(err)
+	}
+
+	exists, _ := xf.Contains([]byte("hello"))
+	fmt.Println("hello exists?:", exists)
+
+	exists, _ = xf.Contains([]byte("other"))
+	fmt.Println("other exists?:", exists)
+
+	data, err := xf.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Serialized size:", len(data))
+
+	decoded := &membership.Xor8{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		panic(err)
+	}
+
+	exists, _ = decoded.Contains([]byte("world"))
+	fmt.Println("world exists after decoding?:", exists)
+}
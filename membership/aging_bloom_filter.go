@@ -0,0 +1,114 @@
+package membership
+
+import (
+	"errors"
+	"time"
+)
+
+// AgingBloomFilter implements a sliding-window Bloom filter made of rotating
+// generations. The window is split into generations of equal span; items are added
+// to the current generation and queries check every live generation. When a span
+// elapses the oldest generation is cleared and reused as the current one, so an
+// item stays visible for at least window and at most window plus one span
+type AgingBloomFilter struct {
+	// generations is a ring of generations+1 filters, head is the current one
+	generations []*BloomFilter
+	head        uint
+	span        time.Duration
+	window      time.Duration
+	start       time.Time
+	now         func() time.Time
+}
+
+// NewAgingBloomFilter creates a new aging Bloom filter
+// capacity: maximum number of elements expected to be added per generation span
+// errorRate: desired false positive probability across all generations
+// window: how long an added item remains visible
+// generations: number of generations the window is split into
+func NewAgingBloomFilter(capacity uint, errorRate float64, window time.Duration, generations uint) (*AgingBloomFilter, error) {
+	if window <= 0 || generations < 1 || time.Duration(generations) > window {
+		return nil, errors.New("invalid window or number of generations")
+	}
+	if capacity < 1 || errorRate <= 0.0 || errorRate >= 1.0 {
+		return nil, errors.New("invalid capacity or error rate")
+	}
+
+	// A query may hit any of the live filters, so each one gets an equal share of the error rate
+	filters := make([]*BloomFilter, generations+1)
+	for i := range filters {
+		bf, err := NewBloomFilter(capacity, errorRate/float64(len(filters)))
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = bf
+	}
+
+	return &AgingBloomFilter{
+		generations: filters,
+		span:        window / time.Duration(generations),
+		window:      window,
+		start:       time.Now(),
+		now:         time.Now,
+	}, nil
+}
+
+// SetClock replaces the clock used to age the generations and starts the current
+// generation at the new clock's time, which makes the filter deterministic in tests
+func (abf *AgingBloomFilter) SetClock(now func() time.Time) {
+	abf.now = now
+	abf.start = now()
+}
+
+// Expire drops the generations whose span has fully left the window
+// Add and Contains call it automatically
+func (abf *AgingBloomFilter) Expire() {
+	elapsed := abf.now().Sub(abf.start)
+	if elapsed < abf.span {
+		return
+	}
+
+	steps := uint(elapsed / abf.span)
+	abf.start = abf.start.Add(time.Duration(steps) * abf.span)
+
+	rings := uint(len(abf.generations))
+	for range min(steps, rings) {
+		abf.head = (abf.head + 1) % rings
+		clear(abf.generations[abf.head].bitArray)
+	}
+}
+
+// Add inserts an item into the current generation
+// Returns error if insertion fails
+func (abf *AgingBloomFilter) Add(item []byte) error {
+	abf.Expire()
+
+	return abf.generations[abf.head].Add(item)
+}
+
+// Contains checks if an item might have been added within the window
+// Returns true if item might be present, false if it was not added or has expired
+func (abf *AgingBloomFilter) Contains(item []byte) (bool, error) {
+	if err := validateInput(item); err != nil {
+		return false, err
+	}
+
+	abf.Expire()
+
+	for _, bf := range abf.generations {
+		if exists, _ := bf.Contains(item); exists {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Window returns the duration an added item remains visible
+func (abf *AgingBloomFilter) Window() time.Duration {
+	return abf.window
+}
+
+// Generations returns the number of generations the window is split into
+func (abf *AgingBloomFilter) Generations() uint {
+	return uint(len(abf.generations)) - 1
+}
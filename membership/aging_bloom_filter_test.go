@@ -0,0 +1,154 @@
+package membership
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for tests
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestAgingBloomFilter(t *testing.T, window time.Duration, generations uint) (*AgingBloomFilter, *fakeClock) {
+	t.Helper()
+
+	abf, err := NewAgingBloomFilter(1000, 0.01, window, generations)
+	if err != nil {
+		t.Fatalf("Failed to create AgingBloomFilter: %v", err)
+	}
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	abf.SetClock(clock.Now)
+	return abf, clock
+}
+
+func TestNewAgingBloomFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		capacity    uint
+		errorRate   float64
+		window      time.Duration
+		generations uint
+		shouldError bool
+	}{
+		{
+			name:        "Valid parameters",
+			capacity:    1000,
+			errorRate:   0.01,
+			window:      10 * time.Minute,
+			generations: 5,
+			shouldError: false,
+		},
+		{
+			name:        "Zero window",
+			capacity:    1000,
+			errorRate:   0.01,
+			window:      0,
+			generations: 5,
+			shouldError: true,
+		},
+		{
+			name:        "Zero generations",
+			capacity:    1000,
+			errorRate:   0.01,
+			window:      time.Minute,
+			generations: 0,
+			shouldError: true,
+		},
+		{
+			name:        "Zero capacity",
+			capacity:    0,
+			errorRate:   0.01,
+			window:      time.Minute,
+			generations: 5,
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAgingBloomFilter(tt.capacity, tt.errorRate, tt.window, tt.generations)
+			if (err != nil) != tt.shouldError {
+				t.Errorf("NewAgingBloomFilter() error = %v, shouldError %v", err, tt.shouldError)
+			}
+		})
+	}
+}
+
+func TestAgingBloomFilter_Window(t *testing.T) {
+	window := 10 * time.Minute
+	abf, clock := newTestAgingBloomFilter(t, window, 5)
+
+	if err := abf.Add([]byte("item")); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	// Still visible at every point inside the window
+	for elapsed := time.Duration(0); elapsed < window; elapsed += time.Minute {
+		if exists, _ := abf.Contains([]byte("item")); !exists {
+			t.Fatalf("Contains() false after %v, want true within window", elapsed)
+		}
+		clock.Advance(time.Minute)
+	}
+
+	// Gone once the window and the partial span have passed
+	clock.Advance(abf.Window() / time.Duration(abf.Generations()))
+	if exists, _ := abf.Contains([]byte("item")); exists {
+		t.Error("Contains() true after the window, want false")
+	}
+}
+
+func TestAgingBloomFilter_Expire(t *testing.T) {
+	abf, clock := newTestAgingBloomFilter(t, time.Hour, 4)
+
+	for i := 0; i < 100; i++ {
+		abf.Add([]byte(fmt.Sprintf("old%d", i)))
+	}
+
+	clock.Advance(30 * time.Minute)
+	for i := 0; i < 100; i++ {
+		abf.Add([]byte(fmt.Sprintf("new%d", i)))
+	}
+
+	clock.Advance(45 * time.Minute)
+	abf.Expire()
+
+	for i := 0; i < 100; i++ {
+		if exists, _ := abf.Contains([]byte(fmt.Sprintf("new%d", i))); !exists {
+			t.Errorf("Contains() false for new%d added 45 minutes ago", i)
+		}
+	}
+
+	expired := 0
+	for i := 0; i < 100; i++ {
+		if exists, _ := abf.Contains([]byte(fmt.Sprintf("old%d", i))); !exists {
+			expired++
+		}
+	}
+	if expired < 95 {
+		t.Errorf("Only %d of 100 old items expired after 75 minutes", expired)
+	}
+
+	// A long pause clears everything
+	clock.Advance(24 * time.Hour)
+	if exists, _ := abf.Contains([]byte("new0")); exists {
+		t.Error("Contains() true after a long pause, want false")
+	}
+
+	if _, err := abf.Contains(nil); err == nil {
+		t.Error("Contains() of nil item should return error")
+	}
+	if err := abf.Add([]byte{}); err == nil {
+		t.Error("Add() of empty item should return error")
+	}
+}
@@ -0,0 +1,147 @@
+package membership
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// BATCH_CHUNK_SIZE is the number of items hashed together before their bits or
+// buckets are updated, which bounds the memory used by the batch operations
+const BATCH_CHUNK_SIZE = 8192
+
+// parallelFor calls fn on contiguous ranges covering [0, n), one per worker
+// workers: number of goroutines, 0 or less uses GOMAXPROCS, 1 runs in the calling goroutine
+func parallelFor(n, workers int, fn func(lo, hi int)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, n)
+	if workers <= 1 {
+		fn(0, n)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		lo, hi := n*w/workers, n*(w+1)/workers
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(lo, hi)
+		}()
+	}
+	wg.Wait()
+}
+
+// joinItemErrors combines the errors of a batch, labelled with the item indices
+func joinItemErrors(errs []error) error {
+	var joined []error
+	for i, err := range errs {
+		if err != nil {
+			joined = append(joined, fmt.Errorf("item %d: %w", i, err))
+		}
+	}
+	return errors.Join(joined...)
+}
+
+// AddBatch inserts items into the Bloom filter. The bit positions of a chunk of
+// items are computed first, across workers, and the bits are then set in one pass,
+// so the memory accesses of different items overlap instead of waiting on each hash
+// workers: number of goroutines used for hashing, 0 or less uses GOMAXPROCS
+// Returns one error per item, nil for the items added, and the errors joined, each
+// labelled with its index
+func (bf *BloomFilter) AddBatch(items [][]byte, workers int) ([]error, error) {
+	errs := make([]error, len(items))
+	k := int(bf.hashFuncCount)
+	positions := make([]uint32, min(len(items), BATCH_CHUNK_SIZE)*k)
+
+	for start := 0; start < len(items); start += BATCH_CHUNK_SIZE {
+		chunk := items[start:min(start+BATCH_CHUNK_SIZE, len(items))]
+
+		parallelFor(len(chunk), workers, func(lo, hi int) {
+			for i := lo; i < hi; i++ {
+				if err := validateInput(chunk[i]); err != nil {
+					errs[start+i] = err
+					continue
+				}
+				for j, hashFunc := range bf.hashFunctions {
+					positions[i*k+j] = hashFunc.Hash(chunk[i]) % uint32(bf.bitCount)
+				}
+			}
+		})
+
+		for i := range chunk {
+			if errs[start+i] != nil {
+				continue
+			}
+			for _, position := range positions[i*k : (i+1)*k] {
+				bf.bitArray[position] = true
+			}
+		}
+	}
+
+	return errs, joinItemErrors(errs)
+}
+
+// ContainsBatch checks which items might be in the Bloom filter, across workers
+// workers: number of goroutines, 0 or less uses GOMAXPROCS
+// Returns one result per item, false for invalid items, and their errors joined
+func (bf *BloomFilter) ContainsBatch(items [][]byte, workers int) ([]bool, error) {
+	results := make([]bool, len(items))
+	errs := make([]error, len(items))
+
+	parallelFor(len(items), workers, func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			results[i], errs[i] = bf.Contains(items[i])
+		}
+	})
+
+	return results, joinItemErrors(errs)
+}
+
+// InsertBatch inserts items into the cuckoo filter. The fingerprints and buckets
+// of a chunk of items are computed across workers; the items are then inserted in
+// order, so the result is the same as inserting them one at a time
+// workers: number of goroutines used for hashing, 0 or less uses GOMAXPROCS
+// Returns one error per item, nil for the items inserted, and the errors joined,
+// each labelled with its index
+func (cf *CuckooFilter) InsertBatch(items [][]byte, workers int) ([]error, error) {
+	errs := make([]error, len(items))
+	size := min(len(items), BATCH_CHUNK_SIZE)
+	fingerprints := make([]byte, size)
+	indices := make([]uint, 2*size)
+
+	for start := 0; start < len(items); start += BATCH_CHUNK_SIZE {
+		chunk := items[start:min(start+BATCH_CHUNK_SIZE, len(items))]
+
+		parallelFor(len(chunk), workers, func(lo, hi int) {
+			for i := lo; i < hi; i++ {
+				fingerprints[i] = cf.generateFingerprint(chunk[i])
+				indices[2*i], indices[2*i+1] = cf.getIndices(chunk[i], fingerprints[i])
+			}
+		})
+
+		for i := range chunk {
+			errs[start+i] = cf.insertHashed(fingerprints[i], 0, indices[2*i], indices[2*i+1])
+		}
+	}
+
+	return errs, joinItemErrors(errs)
+}
+
+// LookupBatch checks which items might be in the cuckoo filter, across workers
+// workers: number of goroutines, 0 or less uses GOMAXPROCS
+// Returns one result per item
+func (cf *CuckooFilter) LookupBatch(items [][]byte, workers int) []bool {
+	results := make([]bool, len(items))
+
+	parallelFor(len(items), workers, func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			results[i] = cf.Lookup(items[i])
+		}
+	})
+
+	return results
+}
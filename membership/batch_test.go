@@ -0,0 +1,203 @@
+package membership
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParallelFor(t *testing.T) {
+	for _, workers := range []int{-1, 0, 1, 3, 8, 100} {
+		seen := make([]int, 50)
+		parallelFor(len(seen), workers, func(lo, hi int) {
+			for i := lo; i < hi; i++ {
+				seen[i]++
+			}
+		})
+		for i, count := range seen {
+			if count != 1 {
+				t.Fatalf("workers %d: index %d visited %d times", workers, i, count)
+			}
+		}
+	}
+
+	parallelFor(0, 4, func(lo, hi int) {
+		if lo != hi {
+			t.Errorf("parallelFor() of no items called fn(%d, %d)", lo, hi)
+		}
+	})
+}
+
+func TestBloomFilter_AddBatch(t *testing.T) {
+	// More items than a chunk, so several chunks are processed
+	items := makeKeys("item", 20000)
+
+	for _, workers := range []int{0, 1, 4} {
+		t.Run(fmt.Sprintf("workers %d", workers), func(t *testing.T) {
+			sequential, _ := NewBloomFilter(20000, 0.01)
+			for _, item := range items {
+				sequential.Add(item)
+			}
+
+			batch, _ := NewBloomFilter(20000, 0.01)
+			if _, err := batch.AddBatch(items, workers); err != nil {
+				t.Fatalf("AddBatch() error = %v", err)
+			}
+			if !reflect.DeepEqual(batch.bitArray, sequential.bitArray) {
+				t.Error("AddBatch() set different bits than Add")
+			}
+
+			results, err := batch.ContainsBatch(append(items, []byte("absent")), workers)
+			if err != nil {
+				t.Fatalf("ContainsBatch() error = %v", err)
+			}
+			for i, item := range items {
+				if !results[i] {
+					t.Fatalf("ContainsBatch() false negative for %s", item)
+				}
+			}
+			if exists, _ := batch.Contains([]byte("absent")); results[len(items)] != exists {
+				t.Error("ContainsBatch() and Contains() disagree")
+			}
+		})
+	}
+}
+
+func TestBloomFilter_BatchErrors(t *testing.T) {
+	bf, _ := NewBloomFilter(100, 0.01)
+	items := [][]byte{[]byte("a"), nil, []byte("b"), {}}
+
+	errs, err := bf.AddBatch(items, 2)
+	if err == nil {
+		t.Fatal("AddBatch() with invalid items should return error")
+	}
+	if !strings.Contains(err.Error(), "item 1") || !strings.Contains(err.Error(), "item 3") {
+		t.Errorf("AddBatch() error = %v, want errors for items 1 and 3", err)
+	}
+	if len(errs) != len(items) || errs[0] != nil || errs[1] == nil || errs[2] != nil || errs[3] == nil {
+		t.Errorf("AddBatch() item errors = %v, want errors for items 1 and 3 only", errs)
+	}
+	if exists, _ := bf.Contains([]byte("b")); !exists {
+		t.Error("AddBatch() skipped a valid item after an invalid one")
+	}
+
+	results, err := bf.ContainsBatch(items, 2)
+	if err == nil || len(results) != len(items) {
+		t.Fatalf("ContainsBatch() = %v, %v, want results and an error", results, err)
+	}
+	if !results[0] || results[1] || !results[2] || results[3] {
+		t.Errorf("ContainsBatch() = %v, want [true false true false]", results)
+	}
+}
+
+func TestCuckooFilter_InsertBatch(t *testing.T) {
+	items := makeKeys("item", 20000)
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers %d", workers), func(t *testing.T) {
+			sequential, _ := NewCuckooFilter(20000, 4, FINGERPRINT_SIZE_8, WithSeed(5))
+			for _, item := range items {
+				sequential.Insert(item)
+			}
+
+			batch, _ := NewCuckooFilter(20000, 4, FINGERPRINT_SIZE_8, WithSeed(5))
+			if _, err := batch.InsertBatch(items, workers); err != nil {
+				t.Fatalf("InsertBatch() error = %v", err)
+			}
+			// Items are inserted in order, so the relocations are the same
+			if !reflect.DeepEqual(batch.buckets, sequential.buckets) || batch.count != sequential.count {
+				t.Error("InsertBatch() produced a different filter than Insert")
+			}
+
+			results := batch.LookupBatch(items, workers)
+			for i, item := range items {
+				if !results[i] {
+					t.Fatalf("LookupBatch() false negative for %s", item)
+				}
+			}
+		})
+	}
+}
+
+func TestCuckooFilter_InsertBatchFull(t *testing.T) {
+	cf, _ := NewCuckooFilter(1000, 4, FINGERPRINT_SIZE_8)
+	items := makeKeys("item", 3000)
+
+	errs, err := cf.InsertBatch(items, 4)
+	if err == nil {
+		t.Fatal("InsertBatch() beyond the capacity should return error")
+	}
+
+	var failed int
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		if errors.Unwrap(e) == nil {
+			t.Errorf("InsertBatch() error %v does not wrap the insertion error", e)
+		}
+		failed++
+	}
+	if uint(failed)+cf.Count() != uint(len(items)) {
+		t.Errorf("%d failed and %d inserted items, want %d in total", failed, cf.Count(), len(items))
+	}
+
+	// The item errors tell which items were rejected
+	rejected := 0
+	for i, itemErr := range errs {
+		if itemErr == nil {
+			continue
+		}
+		rejected++
+		if !strings.Contains(err.Error(), fmt.Sprintf("item %d: %v", i, itemErr)) {
+			t.Errorf("joined error does not report item %d", i)
+		}
+	}
+	if rejected != failed {
+		t.Errorf("%d item errors, want %d", rejected, failed)
+	}
+	if errs[0] != nil {
+		t.Errorf("InsertBatch() rejected the first item: %v", errs[0])
+	}
+}
+
+func BenchmarkBloomFilter_AddBatch(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	bf, err := NewBloomFilter(benchmarkKeys, 0.01)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bf.AddBatch(keys, 0)
+	}
+
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*benchmarkKeys), "ns/key")
+}
+
+func BenchmarkBloomFilter_ContainsBatch(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	bf, err := NewBloomFilter(benchmarkKeys, 0.01)
+	if err != nil {
+		b.Fatal(err)
+	}
+	bf.AddBatch(keys, 0)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bf.ContainsBatch(keys, 0)
+	}
+
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*benchmarkKeys), "ns/key")
+}
+
+func BenchmarkCuckooFilter_InsertBatch(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+
+	for i := 0; i < b.N; i++ {
+		cf, _ := NewCuckooFilter(benchmarkKeys, 4, FINGERPRINT_SIZE_8)
+		cf.InsertBatch(keys, 0)
+	}
+
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*benchmarkKeys), "ns/key")
+}
@@ -0,0 +1,323 @@
+package membership
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+type FUSE_ARITY uint32
+
+const (
+	// BINARY_FUSE_MAX_ITERATIONS is the number of seeds tried before construction fails
+	BINARY_FUSE_MAX_ITERATIONS = 100
+
+	FUSE_ARITY_3 FUSE_ARITY = 3
+	FUSE_ARITY_4 FUSE_ARITY = 4
+
+	binaryFuseMaxSegmentLength = 1 << 18
+)
+
+// binaryFuseLayout describes how hashes are mapped onto the fingerprint array.
+// Each key maps to one slot in each of arity consecutive segments
+type binaryFuseLayout struct {
+	seed               uint64
+	arity              uint32
+	segmentLength      uint32
+	segmentLengthMask  uint32
+	segmentCount       uint32
+	segmentCountLength uint32
+	arrayLength        uint32
+}
+
+// BinaryFuse8 implements a static binary fuse filter (Graf & Lemire) with 8-bit fingerprints
+// With arity 3 it uses about 9.1 bits per key and with arity 4 about 8.6 bits per key,
+// with a false positive rate of about 1/256
+type BinaryFuse8 struct {
+	binaryFuseLayout
+	fingerprints []uint8
+}
+
+// BinaryFuse16 implements a static binary fuse filter (Graf & Lemire) with 16-bit fingerprints
+// With arity 3 it uses about 18.1 bits per key and with arity 4 about 17.2 bits per key,
+// with a false positive rate of about 1/65536
+type BinaryFuse16 struct {
+	binaryFuseLayout
+	fingerprints []uint16
+}
+
+// NewBinaryFuse8 builds an 8-bit binary fuse filter from the given keys
+// arity: number of slots per key, FUSE_ARITY_3 or FUSE_ARITY_4
+// Duplicate keys are ignored
+func NewBinaryFuse8(keys [][]byte, arity FUSE_ARITY) (*BinaryFuse8, error) {
+	hashes, err := hashKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	layout, hashes, found, err := binaryFuseConstruct(hashes, arity)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprints := make([]uint8, layout.arrayLength)
+	var positions [4]uint32
+	for i := len(hashes) - 1; i >= 0; i-- {
+		layout.positions(hashes[i], &positions)
+		fp := uint8(xorFingerprint(hashes[i]))
+		for j := uint32(0); j < layout.arity; j++ {
+			if j != uint32(found[i]) {
+				fp ^= fingerprints[positions[j]]
+			}
+		}
+		fingerprints[positions[found[i]]] = fp
+	}
+
+	return &BinaryFuse8{binaryFuseLayout: layout, fingerprints: fingerprints}, nil
+}
+
+// NewBinaryFuse16 builds a 16-bit binary fuse filter from the given keys
+// arity: number of slots per key, FUSE_ARITY_3 or FUSE_ARITY_4
+// Duplicate keys are ignored
+func NewBinaryFuse16(keys [][]byte, arity FUSE_ARITY) (*BinaryFuse16, error) {
+	hashes, err := hashKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	layout, hashes, found, err := binaryFuseConstruct(hashes, arity)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprints := make([]uint16, layout.arrayLength)
+	var positions [4]uint32
+	for i := len(hashes) - 1; i >= 0; i-- {
+		layout.positions(hashes[i], &positions)
+		fp := uint16(xorFingerprint(hashes[i]))
+		for j := uint32(0); j < layout.arity; j++ {
+			if j != uint32(found[i]) {
+				fp ^= fingerprints[positions[j]]
+			}
+		}
+		fingerprints[positions[found[i]]] = fp
+	}
+
+	return &BinaryFuse16{binaryFuseLayout: layout, fingerprints: fingerprints}, nil
+}
+
+// Contains checks if an item might be in the filter
+// Returns true if item might be present, false if definitely not present
+func (bf *BinaryFuse8) Contains(item []byte) (bool, error) {
+	if err := validateInput(item); err != nil {
+		return false, err
+	}
+
+	hash := mixSplit(hash64(item), bf.seed)
+	var positions [4]uint32
+	bf.positions(hash, &positions)
+
+	fp := uint8(xorFingerprint(hash))
+	for j := uint32(0); j < bf.arity; j++ {
+		fp ^= bf.fingerprints[positions[j]]
+	}
+	return fp == 0, nil
+}
+
+// Contains checks if an item might be in the filter
+// Returns true if item might be present, false if definitely not present
+func (bf *BinaryFuse16) Contains(item []byte) (bool, error) {
+	if err := validateInput(item); err != nil {
+		return false, err
+	}
+
+	hash := mixSplit(hash64(item), bf.seed)
+	var positions [4]uint32
+	bf.positions(hash, &positions)
+
+	fp := uint16(xorFingerprint(hash))
+	for j := uint32(0); j < bf.arity; j++ {
+		fp ^= bf.fingerprints[positions[j]]
+	}
+	return fp == 0, nil
+}
+
+// Size returns the number of fingerprint slots in the filter
+func (bf *BinaryFuse8) Size() uint {
+	return uint(len(bf.fingerprints))
+}
+
+// Size returns the number of fingerprint slots in the filter
+func (bf *BinaryFuse16) Size() uint {
+	return uint(len(bf.fingerprints))
+}
+
+// newBinaryFuseLayout sizes the segments for the given number of keys using the
+// parameters recommended by Graf & Lemire
+func newBinaryFuseLayout(size uint32, arity FUSE_ARITY) binaryFuseLayout {
+	n := float64(max(size, 2))
+
+	var segmentLength uint32
+	sizeFactor := 0.0
+	if arity == FUSE_ARITY_3 {
+		segmentLength = 1 << int(math.Floor(math.Log(n)/math.Log(3.33)+2.25))
+		sizeFactor = math.Max(1.125, 0.875+0.25*math.Log(1000000)/math.Log(n))
+	} else {
+		segmentLength = 1 << int(math.Floor(math.Log(n)/math.Log(2.91)-0.5))
+		sizeFactor = math.Max(1.075, 0.77+0.305*math.Log(600000)/math.Log(n))
+	}
+	segmentLength = max(min(segmentLength, binaryFuseMaxSegmentLength), 4)
+
+	capacity := uint32(0)
+	if size > 1 {
+		capacity = uint32(math.Round(float64(size) * sizeFactor))
+	}
+
+	a := uint32(arity)
+	segmentCount := (capacity + segmentLength - 1) / segmentLength
+	if segmentCount <= a-1 {
+		segmentCount = 1
+	} else {
+		segmentCount -= a - 1
+	}
+
+	return binaryFuseLayout{
+		arity:              a,
+		segmentLength:      segmentLength,
+		segmentLengthMask:  segmentLength - 1,
+		segmentCount:       segmentCount,
+		segmentCountLength: segmentCount * segmentLength,
+		arrayLength:        (segmentCount + a - 1) * segmentLength,
+	}
+}
+
+// positions fills the slots of a hash, one in each of arity consecutive segments
+func (l *binaryFuseLayout) positions(hash uint64, out *[4]uint32) {
+	hi, _ := bits.Mul64(hash, uint64(l.segmentCountLength))
+	h := uint32(hi)
+	out[0] = h
+	out[1] = (h + l.segmentLength) ^ (uint32(hash>>18) & l.segmentLengthMask)
+	out[2] = (h + 2*l.segmentLength) ^ (uint32(hash) & l.segmentLengthMask)
+	if l.arity == 4 {
+		out[3] = (h + 3*l.segmentLength) ^ (uint32(hash>>36) & l.segmentLengthMask)
+	}
+}
+
+// binaryFuseConstruct finds a seed for which the hypergraph of the distinct key
+// hashes can be peeled. It returns the layout, the seeded hashes in peeling order
+// and, for each of them, which of its positions it was assigned to. Fingerprints
+// must be assigned in reverse order
+func binaryFuseConstruct(keys []uint64, arity FUSE_ARITY) (binaryFuseLayout, []uint64, []uint8, error) {
+	if arity != FUSE_ARITY_3 && arity != FUSE_ARITY_4 {
+		return binaryFuseLayout{}, nil, nil, errors.New("invalid arity, must be 3 or 4")
+	}
+
+	size := uint32(len(keys))
+	layout := newBinaryFuseLayout(size, arity)
+
+	// The low two bits of each count hold the xor of the position indices of the
+	// keys in the slot, so a slot with one key knows which position it is
+	counts := make([]uint8, layout.arrayLength)
+	xors := make([]uint64, layout.arrayLength)
+	alone := make([]uint32, layout.arrayLength)
+	order := make([]uint64, size)
+	found := make([]uint8, size)
+
+	blockBits := uint(1)
+	for (uint32(1) << blockBits) < layout.segmentCount {
+		blockBits++
+	}
+	startPos := make([]uint32, 1<<blockBits)
+
+	// The extra entry is a sentinel that moves the last block on to the next one
+	filled := make([]bool, size+1)
+
+	rngState := uint64(0x9b1f7a44c6d2e3f5)
+	var positions [4]uint32
+	for range BINARY_FUSE_MAX_ITERATIONS {
+		layout.seed = splitMix64(&rngState)
+		clear(counts)
+		clear(xors)
+
+		// Bucket the hashes by their first segment so the slots are touched in
+		// nearly sequential order
+		for i := range startPos {
+			startPos[i] = uint32((uint64(i) * uint64(size)) >> blockBits)
+		}
+		clear(filled)
+		filled[size] = true
+		for _, key := range keys {
+			hash := mixSplit(key, layout.seed)
+			block := hash >> (64 - blockBits)
+			for filled[startPos[block]] {
+				block = (block + 1) & ((1 << blockBits) - 1)
+			}
+			order[startPos[block]] = hash
+			filled[startPos[block]] = true
+			startPos[block]++
+		}
+
+		overflow := false
+		for _, hash := range order {
+			layout.positions(hash, &positions)
+			for j := uint32(0); j < layout.arity; j++ {
+				p := positions[j]
+				counts[p] += 4
+				counts[p] ^= uint8(j)
+				xors[p] ^= hash
+				if counts[p] < 4 {
+					overflow = true
+				}
+			}
+		}
+		if overflow {
+			continue
+		}
+
+		queueSize := 0
+		for i := range counts {
+			if counts[i]>>2 == 1 {
+				alone[queueSize] = uint32(i)
+				queueSize++
+			}
+		}
+
+		stackSize := uint32(0)
+		for queueSize > 0 {
+			queueSize--
+			index := alone[queueSize]
+			if counts[index]>>2 != 1 {
+				continue
+			}
+
+			hash := xors[index]
+			position := counts[index] & 3
+			order[stackSize] = hash
+			found[stackSize] = position
+			stackSize++
+
+			layout.positions(hash, &positions)
+			for j := uint32(0); j < layout.arity; j++ {
+				if j == uint32(position) {
+					continue
+				}
+				p := positions[j]
+				counts[p] -= 4
+				counts[p] ^= uint8(j)
+				xors[p] ^= hash
+				if counts[p]>>2 == 1 {
+					alone[queueSize] = p
+					queueSize++
+				}
+			}
+			counts[index] = 0
+			xors[index] = 0
+		}
+
+		if stackSize == size {
+			return layout, order, found, nil
+		}
+	}
+
+	return binaryFuseLayout{}, nil, nil, errors.New("failed to construct binary fuse filter")
+}
@@ -0,0 +1,243 @@
+package membership
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewBinaryFuse8(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    [][]byte
+		arity   FUSE_ARITY
+		wantErr bool
+	}{
+		{
+			name:    "valid keys arity 3",
+			keys:    makeKeys("item", 1000),
+			arity:   FUSE_ARITY_3,
+			wantErr: false,
+		},
+		{
+			name:    "valid keys arity 4",
+			keys:    makeKeys("item", 1000),
+			arity:   FUSE_ARITY_4,
+			wantErr: false,
+		},
+		{
+			name:    "no keys",
+			keys:    [][]byte{},
+			arity:   FUSE_ARITY_3,
+			wantErr: false,
+		},
+		{
+			name:    "single key",
+			keys:    [][]byte{[]byte("a")},
+			arity:   FUSE_ARITY_4,
+			wantErr: false,
+		},
+		{
+			name:    "duplicate keys",
+			keys:    append(makeKeys("item", 100), makeKeys("item", 100)...),
+			arity:   FUSE_ARITY_3,
+			wantErr: false,
+		},
+		{
+			name:    "invalid arity",
+			keys:    makeKeys("item", 10),
+			arity:   5,
+			wantErr: true,
+		},
+		{
+			name:    "nil key",
+			keys:    [][]byte{nil},
+			arity:   FUSE_ARITY_3,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bf, err := NewBinaryFuse8(tt.keys, tt.arity)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewBinaryFuse8() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for _, key := range tt.keys {
+				if exists, _ := bf.Contains(key); !exists {
+					t.Fatalf("Contains() false negative for %s", key)
+				}
+			}
+		})
+	}
+}
+
+func TestBinaryFuse8_FalsePositiveRate(t *testing.T) {
+	for _, arity := range []FUSE_ARITY{FUSE_ARITY_3, FUSE_ARITY_4} {
+		t.Run(fmt.Sprintf("arity %d", arity), func(t *testing.T) {
+			keys := makeKeys("item", 200000)
+			bf, err := NewBinaryFuse8(keys, arity)
+			if err != nil {
+				t.Fatalf("Failed to create BinaryFuse8: %v", err)
+			}
+
+			for _, key := range keys {
+				if exists, _ := bf.Contains(key); !exists {
+					t.Fatalf("Contains() false negative for %s", key)
+				}
+			}
+
+			falsePositives := 0
+			trials := 200000
+			for i := 0; i < trials; i++ {
+				if exists, _ := bf.Contains([]byte(fmt.Sprintf("other%d", i))); exists {
+					falsePositives++
+				}
+			}
+
+			rate := float64(falsePositives) / float64(trials)
+			if rate > 0.006 {
+				t.Errorf("False positive rate too high: got %.5f, want about 1/256", rate)
+			}
+
+			bitsPerKey := float64(8*bf.Size()) / float64(len(keys))
+			if bitsPerKey > 9.5 {
+				t.Errorf("Bits per key too high: got %.2f", bitsPerKey)
+			}
+		})
+	}
+}
+
+func TestBinaryFuse16_Contains(t *testing.T) {
+	for _, arity := range []FUSE_ARITY{FUSE_ARITY_3, FUSE_ARITY_4} {
+		t.Run(fmt.Sprintf("arity %d", arity), func(t *testing.T) {
+			keys := makeKeys("item", 100000)
+			bf, err := NewBinaryFuse16(keys, arity)
+			if err != nil {
+				t.Fatalf("Failed to create BinaryFuse16: %v", err)
+			}
+
+			for _, key := range keys {
+				if exists, _ := bf.Contains(key); !exists {
+					t.Fatalf("Contains() false negative for %s", key)
+				}
+			}
+
+			falsePositives := 0
+			trials := 200000
+			for i := 0; i < trials; i++ {
+				if exists, _ := bf.Contains([]byte(fmt.Sprintf("other%d", i))); exists {
+					falsePositives++
+				}
+			}
+
+			if falsePositives > 20 {
+				t.Errorf("Too many false positives: got %d in %d trials, want about 1/65536", falsePositives, trials)
+			}
+		})
+	}
+}
+
+func TestBinaryFuse8_LargeKeySet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large construction in short mode")
+	}
+
+	keys := makeKeys("key", 2000000)
+	bf, err := NewBinaryFuse8(keys, FUSE_ARITY_4)
+	if err != nil {
+		t.Fatalf("Failed to create BinaryFuse8: %v", err)
+	}
+
+	for i := 0; i < len(keys); i += 997 {
+		if exists, _ := bf.Contains(keys[i]); !exists {
+			t.Fatalf("Contains() false negative for %s", keys[i])
+		}
+	}
+}
+
+// The benchmarks below compare lookups at a false positive rate of about 1/256.
+// The cuckoo filter stores 8-bit fingerprints in buckets of 2, which is the
+// closest configuration it supports (about 4/256)
+const benchmarkKeys = 1000000
+
+func BenchmarkBinaryFuse8_Construct(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewBinaryFuse8(keys, FUSE_ARITY_3); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBinaryFuse8_Contains(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	bf, err := NewBinaryFuse8(keys, FUSE_ARITY_3)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bf.Contains(keys[i%benchmarkKeys])
+	}
+
+	b.ReportMetric(float64(8*bf.Size())/benchmarkKeys, "bits/key")
+}
+
+func BenchmarkBinaryFuse8Arity4_Contains(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	bf, err := NewBinaryFuse8(keys, FUSE_ARITY_4)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bf.Contains(keys[i%benchmarkKeys])
+	}
+
+	b.ReportMetric(float64(8*bf.Size())/benchmarkKeys, "bits/key")
+}
+
+func BenchmarkBloomFilter_ContainsEqualFPR(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	bf, err := NewBloomFilter(benchmarkKeys, 1.0/256)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, key := range keys {
+		bf.Add(key)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bf.Contains(keys[i%benchmarkKeys])
+	}
+
+	// The bit array stores one bool per bit
+	b.ReportMetric(float64(bf.bitCount)/benchmarkKeys, "bits/key")
+}
+
+func BenchmarkCuckooFilter_LookupEqualFPR(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	cf, err := NewCuckooFilter(benchmarkKeys, 2, FINGERPRINT_SIZE_8)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, key := range keys {
+		if err := cf.Insert(key); err != nil {
+			break
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cf.Lookup(keys[i%benchmarkKeys])
+	}
+
+	b.ReportMetric(float64(8*cf.Size())/float64(cf.Count()), "bits/key")
+}
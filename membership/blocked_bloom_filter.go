@@ -0,0 +1,207 @@
+package membership
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+const (
+	// BLOCK_BITS is the number of bits in one block, a 64-byte cache line
+	BLOCK_BITS = 512
+
+	blockWords      = BLOCK_BITS / 64
+	blockedHashSalt = 0x3c6ef372fe94f82b
+	maxBlockedK     = 32
+
+	// blockPositionsPerWord is the number of 9-bit in-block positions in a 64-bit word
+	blockPositionsPerWord = 7
+)
+
+// BlockedBloomFilter implements a cache-line blocked Bloom filter (Putze et al.)
+// Each item first selects one 512-bit block and then sets all k bits inside that
+// block, so every operation touches a single cache line. Blocks receive a varying
+// number of items, which makes the false positive rate slightly higher than that
+// of a BloomFilter with the same m and k, see BlockedBloomFalsePositiveRate
+type BlockedBloomFilter struct {
+	// Large allocations are page aligned by the Go allocator, so each group of
+	// eight words lines up with a cache line
+	words         []uint64
+	blockCount    uint
+	hashFuncCount uint
+	count         uint
+}
+
+// NewBlockedBloomFilter creates a new blocked Bloom filter with specified capacity and error rate
+// The size is chosen with BlockedBloomParams so the blocking overhead is accounted for
+// capacity: maximum number of elements expected to be stored
+// errorRate: desired false positive probability
+func NewBlockedBloomFilter(capacity uint, errorRate float64) (*BlockedBloomFilter, error) {
+	m, k, err := BlockedBloomParams(capacity, errorRate)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBlockedBloomFilterWithParams(m, k)
+}
+
+// NewBlockedBloomFilterWithParams creates a new blocked Bloom filter with specified bit array size and number of hash functions
+// m: size of bit array, rounded up to a multiple of BLOCK_BITS
+// k: number of bits set per item, at most 32
+func NewBlockedBloomFilterWithParams(m, k uint) (*BlockedBloomFilter, error) {
+	if m <= 0 || k <= 0 || k > maxBlockedK {
+		return nil, errors.New("invalid m or k")
+	}
+
+	blockCount := (m + BLOCK_BITS - 1) / BLOCK_BITS
+
+	return &BlockedBloomFilter{
+		words:         make([]uint64, blockCount*blockWords),
+		blockCount:    blockCount,
+		hashFuncCount: k,
+	}, nil
+}
+
+// BlockedBloomParams returns the smallest bit array size m and the number of hash
+// functions k for which a blocked Bloom filter holding capacity items stays at or
+// below errorRate
+func BlockedBloomParams(capacity uint, errorRate float64) (uint, uint, error) {
+	if capacity < 1 || errorRate <= 0.0 || errorRate >= 1.0 {
+		return 0, 0, errors.New("invalid capacity or error rate")
+	}
+
+	// Start from the classic Bloom filter size and grow by 1% until a k is found
+	m := math.Ceil(-1.0 * float64(capacity) * math.Log(errorRate) / LN2SQRD)
+	for {
+		bestK, bestRate := uint(0), 1.0
+		for k := uint(1); k <= maxBlockedK; k++ {
+			rate := BlockedBloomFalsePositiveRate(uint(m), capacity, k)
+			if rate < bestRate {
+				bestK, bestRate = k, rate
+			}
+		}
+
+		if bestRate <= errorRate {
+			blocks := uint(math.Ceil(m / BLOCK_BITS))
+			return blocks * BLOCK_BITS, bestK, nil
+		}
+
+		m *= 1.01
+	}
+}
+
+// BlockedBloomFalsePositiveRate returns the expected false positive rate of a blocked
+// Bloom filter with m bits and k hash functions after n insertions. The load of a
+// block follows a Poisson distribution, and each block behaves like a small Bloom filter
+func BlockedBloomFalsePositiveRate(m, n, k uint) float64 {
+	if n == 0 {
+		return 0
+	}
+
+	blocks := math.Ceil(float64(m) / BLOCK_BITS)
+	lambda := float64(n) / blocks
+	limit := int(lambda + 10*math.Sqrt(lambda) + 10)
+
+	rate := 0.0
+	// Poisson probabilities are computed in log space to avoid overflow
+	for i := 0; i <= limit; i++ {
+		logProbability := float64(i)*math.Log(lambda) - lambda - lgamma(float64(i)+1)
+		blockRate := math.Pow(1-math.Pow(1-1.0/BLOCK_BITS, float64(k)*float64(i)), float64(k))
+		rate += math.Exp(logProbability) * blockRate
+	}
+
+	return rate
+}
+
+func lgamma(x float64) float64 {
+	value, _ := math.Lgamma(x)
+	return value
+}
+
+// positions returns the block of the item and the hash its in-block bit positions are drawn from
+func (bf *BlockedBloomFilter) positions(item []byte) (uint, uint64) {
+	hash := hash64(item)
+	block := uint(reduce(uint32(hash>>32), uint32(bf.blockCount)))
+	return block, hash
+}
+
+// bitPositions calls fn with the k in-block bit positions of an item until fn returns
+// false. Each position takes its own 9 bits of a mixed hash, and a new 64-bit word is
+// mixed every seven positions, so the positions are independent and uniform as the
+// false positive model assumes. Deriving them by double hashing instead would give
+// only 2^18 patterns per block, many of them overlapping
+func (bf *BlockedBloomFilter) bitPositions(hash uint64, fn func(position uint32) bool) {
+	var word uint64
+	for i := uint(0); i < bf.hashFuncCount; i++ {
+		if i%blockPositionsPerWord == 0 {
+			word = mixSplit(hash, blockedHashSalt+uint64(i))
+		}
+		if !fn(uint32(word % BLOCK_BITS)) {
+			return
+		}
+		word /= BLOCK_BITS
+	}
+}
+
+// Add inserts an item into the blocked Bloom filter
+// Returns error if insertion fails
+func (bf *BlockedBloomFilter) Add(item []byte) error {
+	if err := validateInput(item); err != nil {
+		return err
+	}
+
+	block, hash := bf.positions(item)
+	words := bf.words[block*blockWords : (block+1)*blockWords]
+
+	bf.bitPositions(hash, func(position uint32) bool {
+		words[position/64] |= 1 << (position % 64)
+		return true
+	})
+	bf.count++
+
+	return nil
+}
+
+// Contains checks if an item might be in the blocked Bloom filter
+// Returns true if item might be present, false if definitely not present
+func (bf *BlockedBloomFilter) Contains(item []byte) (bool, error) {
+	if err := validateInput(item); err != nil {
+		return false, err
+	}
+
+	block, hash := bf.positions(item)
+	words := bf.words[block*blockWords : (block+1)*blockWords]
+
+	found := true
+	bf.bitPositions(hash, func(position uint32) bool {
+		found = words[position/64]&(1<<(position%64)) != 0
+		return found
+	})
+
+	return found, nil
+}
+
+// Count returns the number of Add calls
+func (bf *BlockedBloomFilter) Count() uint {
+	return bf.count
+}
+
+// Size returns the number of bits in the filter
+func (bf *BlockedBloomFilter) Size() uint {
+	return bf.blockCount * BLOCK_BITS
+}
+
+// FalsePositiveRate returns the expected false positive rate for the items added so far
+func (bf *BlockedBloomFilter) FalsePositiveRate() float64 {
+	return BlockedBloomFalsePositiveRate(bf.Size(), bf.count, bf.hashFuncCount)
+}
+
+// FillRatio returns the fraction of bits that are set
+func (bf *BlockedBloomFilter) FillRatio() float64 {
+	set := 0
+	for _, word := range bf.words {
+		set += bits.OnesCount64(word)
+	}
+
+	return float64(set) / float64(bf.Size())
+}
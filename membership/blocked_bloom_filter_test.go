@@ -0,0 +1,207 @@
+package membership
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewBlockedBloomFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		capacity  uint
+		errorRate float64
+		wantErr   bool
+	}{
+		{
+			name:      "valid parameters",
+			capacity:  10000,
+			errorRate: 0.01,
+			wantErr:   false,
+		},
+		{
+			name:      "zero capacity",
+			capacity:  0,
+			errorRate: 0.01,
+			wantErr:   true,
+		},
+		{
+			name:      "error rate too high",
+			capacity:  1000,
+			errorRate: 1.0,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewBlockedBloomFilter(tt.capacity, tt.errorRate)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewBlockedBloomFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewBlockedBloomFilterWithParams(1024, 33); err == nil {
+		t.Error("NewBlockedBloomFilterWithParams() with k > 32 should return error")
+	}
+}
+
+func TestBlockedBloomFilter_AddContains(t *testing.T) {
+	bf, err := NewBlockedBloomFilter(1000, 0.01)
+	if err != nil {
+		t.Fatalf("Failed to create BlockedBloomFilter: %v", err)
+	}
+
+	if err := bf.Add([]byte("item1")); err != nil {
+		t.Errorf("Add() error = %v", err)
+	}
+
+	if exists, _ := bf.Contains([]byte("item1")); !exists {
+		t.Error("Contains() failed to find added item")
+	}
+
+	if exists, _ := bf.Contains([]byte("non-existing")); exists {
+		t.Error("Contains() found non-existent item")
+	}
+
+	if err := bf.Add(nil); err == nil {
+		t.Error("Add() of nil item should return error")
+	}
+
+	if _, err := bf.Contains([]byte{}); err == nil {
+		t.Error("Contains() of empty item should return error")
+	}
+}
+
+func TestBlockedBloomParams(t *testing.T) {
+	capacity := uint(100000)
+	for _, errorRate := range []float64{0.1, 0.01, 0.001} {
+		m, k, err := BlockedBloomParams(capacity, errorRate)
+		if err != nil {
+			t.Fatalf("BlockedBloomParams() error = %v", err)
+		}
+
+		if m%BLOCK_BITS != 0 {
+			t.Errorf("BlockedBloomParams() m = %d is not a multiple of %d", m, BLOCK_BITS)
+		}
+
+		if rate := BlockedBloomFalsePositiveRate(m, capacity, k); rate > errorRate {
+			t.Errorf("BlockedBloomParams(%v) gives rate %v", errorRate, rate)
+		}
+
+		// Blocking needs more space than a classic Bloom filter for the same rate
+		bloom, _ := NewBloomFilter(capacity, errorRate)
+		if m <= bloom.bitCount {
+			t.Errorf("BlockedBloomParams(%v) m = %d, want more than classic %d", errorRate, m, bloom.bitCount)
+		}
+	}
+}
+
+func TestBlockedBloomFilter_FalsePositiveRate(t *testing.T) {
+	capacity := uint(100000)
+	// Enough trials to see about 100 false positives at each rate
+	tests := []struct {
+		errorRate float64
+		trials    int
+	}{
+		{errorRate: 0.01, trials: 200000},
+		{errorRate: 0.001, trials: 200000},
+		{errorRate: 0.0001, trials: 1000000},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("error rate %v", tt.errorRate), func(t *testing.T) {
+			bf, err := NewBlockedBloomFilter(capacity, tt.errorRate)
+			if err != nil {
+				t.Fatalf("Failed to create BlockedBloomFilter: %v", err)
+			}
+
+			for i := uint(0); i < capacity; i++ {
+				item := []byte(fmt.Sprintf("item%d", i))
+				if err := bf.Add(item); err != nil {
+					t.Fatalf("Add() error = %v", err)
+				}
+			}
+
+			for i := uint(0); i < capacity; i++ {
+				if exists, _ := bf.Contains([]byte(fmt.Sprintf("item%d", i))); !exists {
+					t.Fatalf("Contains() false negative for item%d", i)
+				}
+			}
+
+			falsePositives := 0
+			for i := 0; i < tt.trials; i++ {
+				if exists, _ := bf.Contains([]byte(fmt.Sprintf("other%d", i))); exists {
+					falsePositives++
+				}
+			}
+
+			rate := float64(falsePositives) / float64(tt.trials)
+			predicted := bf.FalsePositiveRate()
+			if rate > 1.3*tt.errorRate {
+				t.Errorf("False positive rate too high: got %.6f, want <= %.6f", rate, tt.errorRate)
+			}
+			if rate < 0.7*predicted || rate > 1.3*predicted {
+				t.Errorf("False positive rate %.6f does not match prediction %.6f", rate, predicted)
+			}
+		})
+	}
+}
+
+func BenchmarkBlockedBloomFilter_Add(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	bf, err := NewBlockedBloomFilter(benchmarkKeys, 0.01)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bf.Add(keys[i%benchmarkKeys])
+	}
+}
+
+func BenchmarkBloomFilter_Add(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	bf, err := NewBloomFilter(benchmarkKeys, 0.01)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bf.Add(keys[i%benchmarkKeys])
+	}
+}
+
+func BenchmarkBlockedBloomFilter_Contains(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	bf, err := NewBlockedBloomFilter(benchmarkKeys, 0.01)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, key := range keys {
+		bf.Add(key)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bf.Contains(keys[i%benchmarkKeys])
+	}
+}
+
+func BenchmarkBloomFilter_Contains(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	bf, err := NewBloomFilter(benchmarkKeys, 0.01)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, key := range keys {
+		bf.Add(key)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bf.Contains(keys[i%benchmarkKeys])
+	}
+}
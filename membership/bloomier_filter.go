@@ -0,0 +1,173 @@
+package membership
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const bloomierFilterHeaderSize = 13
+
+// BloomierFilter implements a static function from a key set to values of
+// valueBits bits (Chazelle et al.), built with the same 3-hypergraph peeling as
+// the xor filter. The value of a key is the xor of its three slots, so Get
+// returns the stored value for every member; for other keys it returns an
+// arbitrary value. Keys are not stored and the table uses about 1.23*valueBits
+// bits per key
+type BloomierFilter struct {
+	seed        uint64
+	blockLength uint32
+	valueBits   uint
+	// slots holds 3*blockLength values of valueBits bits, packed little-endian
+	slots []uint64
+}
+
+// NewBloomierFilter builds a Bloomier filter mapping keys[i] to values[i]
+// valueBits: number of bits per value, between 1 and 64
+// Duplicate keys must map to the same value
+func NewBloomierFilter(keys [][]byte, values []uint64, valueBits uint) (*BloomierFilter, error) {
+	if valueBits < 1 || valueBits > 64 {
+		return nil, errors.New("invalid value bits, must be between 1 and 64")
+	}
+
+	if len(keys) != len(values) {
+		return nil, errors.New("keys and values have different lengths")
+	}
+
+	mask := valueMask(valueBits)
+	assigned := make(map[uint64]uint64, len(keys))
+	for i, key := range keys {
+		if err := validateInput(key); err != nil {
+			return nil, err
+		}
+		if values[i] > mask {
+			return nil, errors.New("value does not fit in value bits")
+		}
+
+		hash := hash64(key)
+		if value, ok := assigned[hash]; ok && value != values[i] {
+			return nil, errors.New("key mapped to two different values")
+		}
+		assigned[hash] = values[i]
+	}
+
+	hashes, err := hashKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, blockLength, stack, err := xorConstruct(hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	// The stack holds seeded hashes, so map them back to the values
+	seeded := make(map[uint64]uint64, len(hashes))
+	for _, hash := range hashes {
+		seeded[mixSplit(hash, seed)] = assigned[hash]
+	}
+
+	bf := &BloomierFilter{
+		seed:        seed,
+		blockLength: blockLength,
+		valueBits:   valueBits,
+		slots:       make([]uint64, (3*uint64(blockLength)*uint64(valueBits)+63)/64),
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		h0, h1, h2 := xorPositions(stack[i].hash, blockLength)
+		value := seeded[stack[i].hash] ^ bf.slot(h0) ^ bf.slot(h1) ^ bf.slot(h2)
+		bf.setSlot(stack[i].index, value)
+	}
+
+	return bf, nil
+}
+
+// Get returns the value stored for a key
+// The result is only meaningful for keys the filter was built from
+func (bf *BloomierFilter) Get(item []byte) (uint64, error) {
+	if err := validateInput(item); err != nil {
+		return 0, err
+	}
+
+	hash := mixSplit(hash64(item), bf.seed)
+	h0, h1, h2 := xorPositions(hash, bf.blockLength)
+	return bf.slot(h0) ^ bf.slot(h1) ^ bf.slot(h2), nil
+}
+
+// Size returns the number of value slots in the filter
+func (bf *BloomierFilter) Size() uint {
+	return 3 * uint(bf.blockLength)
+}
+
+// SizeInBits returns the number of bits used by the value slots
+func (bf *BloomierFilter) SizeInBits() uint {
+	return bf.Size() * bf.valueBits
+}
+
+// slot reads the value at index i
+func (bf *BloomierFilter) slot(i uint32) uint64 {
+	bit := uint64(i) * uint64(bf.valueBits)
+	word, offset := bit/64, bit%64
+
+	value := bf.slots[word] >> offset
+	if offset+uint64(bf.valueBits) > 64 {
+		value |= bf.slots[word+1] << (64 - offset)
+	}
+	return value & valueMask(bf.valueBits)
+}
+
+// setSlot writes the value at index i
+func (bf *BloomierFilter) setSlot(i uint32, value uint64) {
+	bit := uint64(i) * uint64(bf.valueBits)
+	word, offset := bit/64, bit%64
+	mask := valueMask(bf.valueBits)
+
+	bf.slots[word] = bf.slots[word]&^(mask<<offset) | value<<offset
+	if offset+uint64(bf.valueBits) > 64 {
+		shift := 64 - offset
+		bf.slots[word+1] = bf.slots[word+1]&^(mask>>shift) | value>>shift
+	}
+}
+
+func valueMask(valueBits uint) uint64 {
+	return ^uint64(0) >> (64 - valueBits)
+}
+
+// MarshalBinary encodes the filter as seed, block length, value bits and the
+// packed slot words in little-endian order
+func (bf *BloomierFilter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, bloomierFilterHeaderSize+8*len(bf.slots))
+	binary.LittleEndian.PutUint64(data[0:8], bf.seed)
+	binary.LittleEndian.PutUint32(data[8:12], bf.blockLength)
+	data[12] = byte(bf.valueBits)
+	for i, word := range bf.slots {
+		binary.LittleEndian.PutUint64(data[bloomierFilterHeaderSize+8*i:], word)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes a filter produced by MarshalBinary
+func (bf *BloomierFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < bloomierFilterHeaderSize {
+		return errors.New("data too short for bloomier filter header")
+	}
+
+	blockLength := binary.LittleEndian.Uint32(data[8:12])
+	valueBits := uint(data[12])
+	if valueBits < 1 || valueBits > 64 {
+		return errors.New("invalid bloomier filter header")
+	}
+
+	words := (3*uint64(blockLength)*uint64(valueBits) + 63) / 64
+	if uint64(len(data)-bloomierFilterHeaderSize) != 8*words {
+		return errors.New("data length does not match bloomier filter size")
+	}
+
+	bf.seed = binary.LittleEndian.Uint64(data[0:8])
+	bf.blockLength = blockLength
+	bf.valueBits = valueBits
+	bf.slots = make([]uint64, words)
+	for i := range bf.slots {
+		bf.slots[i] = binary.LittleEndian.Uint64(data[bloomierFilterHeaderSize+8*i:])
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package membership
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestNewBloomierFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      [][]byte
+		values    []uint64
+		valueBits uint
+		wantErr   bool
+	}{
+		{
+			name:      "valid keys",
+			keys:      [][]byte{[]byte("a"), []byte("b"), []byte("c")},
+			values:    []uint64{1, 2, 3},
+			valueBits: 4,
+			wantErr:   false,
+		},
+		{
+			name:      "no keys",
+			keys:      [][]byte{},
+			values:    []uint64{},
+			valueBits: 4,
+			wantErr:   false,
+		},
+		{
+			name:      "duplicate keys with same value",
+			keys:      [][]byte{[]byte("a"), []byte("a")},
+			values:    []uint64{5, 5},
+			valueBits: 4,
+			wantErr:   false,
+		},
+		{
+			name:      "duplicate keys with different values",
+			keys:      [][]byte{[]byte("a"), []byte("a")},
+			values:    []uint64{5, 6},
+			valueBits: 4,
+			wantErr:   true,
+		},
+		{
+			name:      "value too large",
+			keys:      [][]byte{[]byte("a")},
+			values:    []uint64{16},
+			valueBits: 4,
+			wantErr:   true,
+		},
+		{
+			name:      "length mismatch",
+			keys:      [][]byte{[]byte("a"), []byte("b")},
+			values:    []uint64{1},
+			valueBits: 4,
+			wantErr:   true,
+		},
+		{
+			name:      "invalid value bits",
+			keys:      [][]byte{[]byte("a")},
+			values:    []uint64{1},
+			valueBits: 65,
+			wantErr:   true,
+		},
+		{
+			name:      "empty key",
+			keys:      [][]byte{{}},
+			values:    []uint64{1},
+			valueBits: 4,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bf, err := NewBloomierFilter(tt.keys, tt.values, tt.valueBits)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewBloomierFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for i, key := range tt.keys {
+				if value, _ := bf.Get(key); value != tt.values[i] {
+					t.Errorf("Get(%s) = %d, want %d", key, value, tt.values[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBloomierFilter_Get(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, valueBits := range []uint{1, 4, 7, 13, 64} {
+		t.Run(fmt.Sprintf("%d bits", valueBits), func(t *testing.T) {
+			keys := makeKeys("item", 50000)
+			values := make([]uint64, len(keys))
+			for i := range values {
+				values[i] = rng.Uint64() & valueMask(valueBits)
+			}
+
+			bf, err := NewBloomierFilter(keys, values, valueBits)
+			if err != nil {
+				t.Fatalf("Failed to create BloomierFilter: %v", err)
+			}
+
+			for i, key := range keys {
+				if value, _ := bf.Get(key); value != values[i] {
+					t.Fatalf("Get(%s) = %d, want %d", key, value, values[i])
+				}
+			}
+
+			if value, _ := bf.Get([]byte("other")); value > valueMask(valueBits) {
+				t.Errorf("Get() of non-member = %d, exceeds %d bits", value, valueBits)
+			}
+
+			bitsPerKey := float64(bf.SizeInBits()) / float64(len(keys))
+			if bitsPerKey > 1.25*float64(valueBits) {
+				t.Errorf("Bits per key too high: got %.2f", bitsPerKey)
+			}
+		})
+	}
+
+	bf, _ := NewBloomierFilter(nil, nil, 4)
+	if _, err := bf.Get(nil); err == nil {
+		t.Error("Get() of nil item should return error")
+	}
+}
+
+func TestBloomierFilter_Serialization(t *testing.T) {
+	keys := makeKeys("item", 10000)
+	values := make([]uint64, len(keys))
+	for i := range values {
+		values[i] = uint64(i % 16)
+	}
+
+	bf, err := NewBloomierFilter(keys, values, 4)
+	if err != nil {
+		t.Fatalf("Failed to create BloomierFilter: %v", err)
+	}
+
+	data, err := bf.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	decoded := &BloomierFilter{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	for i, key := range keys {
+		if value, _ := decoded.Get(key); value != values[i] {
+			t.Fatalf("Get(%s) after decoding = %d, want %d", key, value, values[i])
+		}
+	}
+
+	if err := decoded.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("UnmarshalBinary() of truncated data should return error")
+	}
+}
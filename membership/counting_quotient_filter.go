@@ -0,0 +1,322 @@
+package membership
+
+import (
+	"errors"
+	"math"
+)
+
+// CountingQuotientFilter implements a counting quotient filter (Pandey et al.), a
+// quotient filter that stores the multiplicity of each remainder in-line using a
+// variable-length counter encoding, so frequent items take only a few slots
+//
+// Within a run, a remainder x with count c is encoded as
+//   - c = 1: x
+//   - c = 2: x, x
+//   - c > 2, x > 0: x, [0], digits..., x
+//     where the digits encode c-3 in base 2^r-2 using symbols other than 0 and x.
+//     A leading 0 is added when the first symbol is larger than x, so a value
+//     smaller than x right after it always marks a counter
+//   - c = 3, x = 0: 0, 0, 0
+//   - c > 3, x = 0: 0, digits..., 0, 0
+//     where the digits encode c-4 in base 2^r-1 using nonzero symbols
+type CountingQuotientFilter struct {
+	table *QuotientFilter
+	count uint64
+}
+
+// cqfGroup is a decoded remainder and its multiplicity
+type cqfGroup struct {
+	remainder uint64
+	count     uint64
+}
+
+// NewCountingQuotientFilter creates a new counting quotient filter with specified capacity and error rate
+// capacity: maximum number of distinct elements expected to be stored
+// errorRate: desired false positive probability
+func NewCountingQuotientFilter(capacity uint, errorRate float64) (*CountingQuotientFilter, error) {
+	if capacity < 1 || errorRate <= 0.0 || errorRate >= 1.0 {
+		return nil, errors.New("invalid capacity or error rate")
+	}
+
+	quotientBits := uint(math.Ceil(math.Log2(float64(capacity) / QUOTIENT_FILTER_MAX_LOAD)))
+	if quotientBits < 1 {
+		quotientBits = 1
+	}
+	remainderBits := max(uint(math.Ceil(-1.0*math.Log2(errorRate))), 2)
+
+	return NewCountingQuotientFilterWithParams(quotientBits, remainderBits)
+}
+
+// NewCountingQuotientFilterWithParams creates a new counting quotient filter with 2^q slots holding r-bit remainders
+// q: number of quotient bits
+// r: number of remainder bits, at least 2 so counters can be encoded
+func NewCountingQuotientFilterWithParams(q, r uint) (*CountingQuotientFilter, error) {
+	if r < 2 {
+		return nil, errors.New("invalid r, must be at least 2")
+	}
+
+	table, err := NewQuotientFilterWithParams(q, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CountingQuotientFilter{table: table}, nil
+}
+
+// Insert adds n occurrences of an item to the filter
+// Returns error if the item is invalid or the filter has no room for the encoding
+func (cqf *CountingQuotientFilter) Insert(item []byte, n uint64) error {
+	if err := validateInput(item); err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	quotient, remainder := cqf.table.fingerprint(item)
+	groups := cqf.decodeRun(cqf.table.readRun(quotient))
+
+	position := len(groups)
+	for i, group := range groups {
+		if group.remainder >= remainder {
+			position = i
+			break
+		}
+	}
+
+	if position < len(groups) && groups[position].remainder == remainder {
+		if groups[position].count > math.MaxUint64-n {
+			return errors.New("counter overflow")
+		}
+		groups[position].count += n
+	} else {
+		groups = append(groups, cqfGroup{})
+		copy(groups[position+1:], groups[position:])
+		groups[position] = cqfGroup{remainder: remainder, count: n}
+	}
+
+	if err := cqf.updateRun(quotient, groups); err != nil {
+		return err
+	}
+
+	cqf.count += n
+	return nil
+}
+
+// Count returns the estimated number of occurrences of an item
+// The estimate is never lower than the true count, it is higher only when another
+// item shares the same fingerprint
+func (cqf *CountingQuotientFilter) Count(item []byte) (uint64, error) {
+	if err := validateInput(item); err != nil {
+		return 0, err
+	}
+
+	quotient, remainder := cqf.table.fingerprint(item)
+	for _, group := range cqf.decodeRun(cqf.table.readRun(quotient)) {
+		if group.remainder == remainder {
+			return group.count, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// Contains checks if an item might be in the filter
+// Returns true if item might be present, false if definitely not present
+func (cqf *CountingQuotientFilter) Contains(item []byte) (bool, error) {
+	count, err := cqf.Count(item)
+	return count > 0, err
+}
+
+// Remove deletes up to n occurrences of an item from the filter
+// Returns the number of occurrences actually removed
+func (cqf *CountingQuotientFilter) Remove(item []byte, n uint64) (uint64, error) {
+	if err := validateInput(item); err != nil {
+		return 0, err
+	}
+
+	quotient, remainder := cqf.table.fingerprint(item)
+	groups := cqf.decodeRun(cqf.table.readRun(quotient))
+
+	for i, group := range groups {
+		if group.remainder != remainder {
+			continue
+		}
+
+		removed := min(n, group.count)
+		if removed == group.count {
+			groups = append(groups[:i], groups[i+1:]...)
+		} else {
+			groups[i].count -= removed
+		}
+
+		// Shrinking a run always frees slots, so this cannot fail
+		if err := cqf.updateRun(quotient, groups); err != nil {
+			return 0, err
+		}
+
+		cqf.count -= removed
+		return removed, nil
+	}
+
+	return 0, nil
+}
+
+// updateRun encodes the groups and writes them back as the run of quotient
+func (cqf *CountingQuotientFilter) updateRun(quotient uint64, groups []cqfGroup) error {
+	encoded := make([]uint64, 0, len(groups))
+	for _, group := range groups {
+		encoded = cqf.encodeGroup(encoded, group)
+	}
+
+	current := uint(len(cqf.table.readRun(quotient)))
+	if needed := uint(len(encoded)); needed > current && cqf.table.count+needed-current > cqf.table.Size() {
+		return ErrFilterFull
+	}
+
+	cqf.table.writeRun(quotient, encoded)
+	return nil
+}
+
+// encodeGroup appends the slot encoding of a remainder and its count
+func (cqf *CountingQuotientFilter) encodeGroup(dst []uint64, group cqfGroup) []uint64 {
+	x := group.remainder
+	switch {
+	case group.count == 1:
+		return append(dst, x)
+	case group.count == 2:
+		return append(dst, x, x)
+	case x == 0 && group.count == 3:
+		return append(dst, 0, 0, 0)
+	case x == 0:
+		dst = append(dst, 0)
+		for _, digit := range cqf.digits(group.count-4, cqf.table.remainderMask) {
+			dst = append(dst, digit+1)
+		}
+		return append(dst, 0, 0)
+	}
+
+	symbols := cqf.digits(group.count-3, cqf.table.remainderMask-1)
+	for i, digit := range symbols {
+		// Skip the reserved symbols 0 and x
+		digit++
+		if digit >= x {
+			digit++
+		}
+		symbols[i] = digit
+	}
+
+	dst = append(dst, x)
+	if symbols[0] > x {
+		dst = append(dst, 0)
+	}
+	dst = append(dst, symbols...)
+	return append(dst, x)
+}
+
+// digits returns the base representation of value, most significant digit first
+func (cqf *CountingQuotientFilter) digits(value, base uint64) []uint64 {
+	result := []uint64{value % base}
+	for value /= base; value > 0; value /= base {
+		result = append(result, value%base)
+	}
+
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return result
+}
+
+// decodeRun converts the slots of a run back into remainders and counts
+func (cqf *CountingQuotientFilter) decodeRun(run []uint64) []cqfGroup {
+	groups := make([]cqfGroup, 0, len(run))
+
+	i := 0
+	if len(run) > 0 && run[0] == 0 {
+		group, next := cqf.decodeZero(run)
+		groups = append(groups, group)
+		i = next
+	}
+
+	for i < len(run) {
+		x := run[i]
+		i++
+
+		if i == len(run) || run[i] > x {
+			groups = append(groups, cqfGroup{remainder: x, count: 1})
+			continue
+		}
+
+		if run[i] == x {
+			groups = append(groups, cqfGroup{remainder: x, count: 2})
+			i++
+			continue
+		}
+
+		// A smaller value marks a counter, with an optional leading 0
+		if run[i] == 0 {
+			i++
+		}
+		base := cqf.table.remainderMask - 1
+		value := uint64(0)
+		for ; run[i] != x; i++ {
+			digit := run[i] - 1
+			if run[i] > x {
+				digit--
+			}
+			value = value*base + digit
+		}
+		i++
+
+		groups = append(groups, cqfGroup{remainder: x, count: value + 3})
+	}
+
+	return groups
+}
+
+// decodeZero decodes the group of remainder 0 at the start of a run and returns
+// the index of the first slot after it
+func (cqf *CountingQuotientFilter) decodeZero(run []uint64) (cqfGroup, int) {
+	if len(run) > 1 && run[1] == 0 {
+		if len(run) > 2 && run[2] == 0 {
+			return cqfGroup{remainder: 0, count: 3}, 3
+		}
+		return cqfGroup{remainder: 0, count: 2}, 2
+	}
+
+	// Nonzero digits terminated by two zeros form a counter, otherwise the next
+	// value starts another group. Counters of other remainders never hold two
+	// consecutive zeros
+	end := 1
+	for end < len(run) && run[end] != 0 {
+		end++
+	}
+	if end == 1 || end+1 >= len(run) || run[end+1] != 0 {
+		return cqfGroup{remainder: 0, count: 1}, 1
+	}
+
+	base := cqf.table.remainderMask
+	value := uint64(0)
+	for _, symbol := range run[1:end] {
+		value = value*base + symbol - 1
+	}
+
+	return cqfGroup{remainder: 0, count: value + 4}, end + 2
+}
+
+// TotalCount returns the total number of occurrences stored in the filter
+func (cqf *CountingQuotientFilter) TotalCount() uint64 {
+	return cqf.count
+}
+
+// LoadFactor returns the fraction of occupied slots
+func (cqf *CountingQuotientFilter) LoadFactor() float64 {
+	return cqf.table.LoadFactor()
+}
+
+// Size returns the number of slots in the filter
+func (cqf *CountingQuotientFilter) Size() uint {
+	return cqf.table.Size()
+}
@@ -0,0 +1,210 @@
+package membership
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestNewCountingQuotientFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		capacity  uint
+		errorRate float64
+		wantErr   bool
+	}{
+		{
+			name:      "valid parameters",
+			capacity:  1000,
+			errorRate: 0.01,
+			wantErr:   false,
+		},
+		{
+			name:      "zero capacity",
+			capacity:  0,
+			errorRate: 0.01,
+			wantErr:   true,
+		},
+		{
+			name:      "error rate too low",
+			capacity:  1000,
+			errorRate: 0.0,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCountingQuotientFilter(tt.capacity, tt.errorRate)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewCountingQuotientFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewCountingQuotientFilterWithParams(8, 1); err == nil {
+		t.Error("NewCountingQuotientFilterWithParams() with r = 1 should return error")
+	}
+}
+
+func TestCountingQuotientFilter_Encoding(t *testing.T) {
+	for _, r := range []uint{2, 3, 8} {
+		cqf, err := NewCountingQuotientFilterWithParams(4, r)
+		if err != nil {
+			t.Fatalf("Failed to create CountingQuotientFilter: %v", err)
+		}
+
+		counts := []uint64{1, 2, 3, 4, 5, 7, 100, 1 << 20, 1<<63 + 12345}
+		for x := uint64(0); x < 1<<r && x < 16; x++ {
+			for _, count := range counts {
+				// Encode a run holding a group before and after x to catch overlaps
+				groups := []cqfGroup{{remainder: x, count: count}}
+				if x > 0 {
+					groups = append([]cqfGroup{{remainder: 0, count: count}}, groups...)
+				}
+				if x+1 < 1<<r {
+					groups = append(groups, cqfGroup{remainder: x + 1, count: 3})
+				}
+
+				encoded := make([]uint64, 0)
+				for _, group := range groups {
+					encoded = cqf.encodeGroup(encoded, group)
+				}
+
+				decoded := cqf.decodeRun(encoded)
+				if fmt.Sprint(decoded) != fmt.Sprint(groups) {
+					t.Errorf("r=%d: decodeRun(%v) = %v, want %v", r, encoded, decoded, groups)
+				}
+			}
+		}
+	}
+}
+
+func TestCountingQuotientFilter_BasicOperations(t *testing.T) {
+	cqf, err := NewCountingQuotientFilter(1000, 0.01)
+	if err != nil {
+		t.Fatalf("Failed to create CountingQuotientFilter: %v", err)
+	}
+
+	item := []byte("test1")
+	if err := cqf.Insert(item, 1); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if err := cqf.Insert(item, 41); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	if count, _ := cqf.Count(item); count != 42 {
+		t.Errorf("Count() = %d, want 42", count)
+	}
+
+	if exists, _ := cqf.Contains([]byte("test2")); exists {
+		t.Error("Contains() found non-existent item")
+	}
+
+	if removed, _ := cqf.Remove(item, 40); removed != 40 {
+		t.Errorf("Remove() = %d, want 40", removed)
+	}
+
+	if count, _ := cqf.Count(item); count != 2 {
+		t.Errorf("Count() after Remove() = %d, want 2", count)
+	}
+
+	if removed, _ := cqf.Remove(item, 10); removed != 2 {
+		t.Errorf("Remove() = %d, want 2", removed)
+	}
+
+	if exists, _ := cqf.Contains(item); exists {
+		t.Error("Contains() found fully removed item")
+	}
+
+	if cqf.TotalCount() != 0 || cqf.LoadFactor() != 0 {
+		t.Errorf("Empty filter has TotalCount() = %d, LoadFactor() = %f", cqf.TotalCount(), cqf.LoadFactor())
+	}
+
+	if err := cqf.Insert(nil, 1); err == nil {
+		t.Error("Insert() of nil item should return error")
+	}
+}
+
+func TestCountingQuotientFilter_RandomOperations(t *testing.T) {
+	cqf, err := NewCountingQuotientFilterWithParams(8, 3)
+	if err != nil {
+		t.Fatalf("Failed to create CountingQuotientFilter: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(3))
+	reference := make(map[string]uint64)
+	for step := 0; step < 5000; step++ {
+		item := []byte(fmt.Sprintf("item%d", rng.Intn(60)))
+		n := uint64(rng.Intn(20) + 1)
+
+		if rng.Intn(3) > 0 {
+			if err := cqf.Insert(item, n); err != nil {
+				continue
+			}
+			reference[string(item)] += n
+		} else {
+			// Removing only what was inserted keeps colliding items intact
+			n = min(n, reference[string(item)])
+			removed, err := cqf.Remove(item, n)
+			if err != nil {
+				t.Fatalf("Remove() error = %v", err)
+			}
+			if removed != n {
+				t.Fatalf("step %d: Remove() = %d, want %d", step, removed, n)
+			}
+			reference[string(item)] -= n
+		}
+
+		for key, want := range reference {
+			got, _ := cqf.Count([]byte(key))
+			if got < want {
+				t.Fatalf("step %d: Count(%s) = %d, want at least %d", step, key, got, want)
+			}
+		}
+	}
+}
+
+func TestCountingQuotientFilter_Zipfian(t *testing.T) {
+	cqf, err := NewCountingQuotientFilter(20000, 0.001)
+	if err != nil {
+		t.Fatalf("Failed to create CountingQuotientFilter: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(4))
+	zipf := rand.NewZipf(rng, 1.2, 1, 100000)
+	reference := make(map[uint64]uint64)
+	total := 200000
+	for i := 0; i < total; i++ {
+		key := zipf.Uint64()
+		if err := cqf.Insert([]byte(fmt.Sprintf("key%d", key)), 1); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+		reference[key]++
+	}
+
+	exact := 0
+	for key, want := range reference {
+		got, _ := cqf.Count([]byte(fmt.Sprintf("key%d", key)))
+		if got < want {
+			t.Fatalf("Count(key%d) = %d, want at least %d", key, got, want)
+		}
+		if got == want {
+			exact++
+		}
+	}
+
+	if float64(exact) < 0.99*float64(len(reference)) {
+		t.Errorf("Too many overcounted keys: %d of %d exact", exact, len(reference))
+	}
+
+	// Counters keep slot usage close to the number of distinct keys
+	if slots := cqf.table.count; slots > 2*uint(len(reference)) {
+		t.Errorf("Used %d slots for %d distinct keys and %d items", slots, len(reference), total)
+	}
+
+	if cqf.TotalCount() != uint64(total) {
+		t.Errorf("TotalCount() = %d, want %d", cqf.TotalCount(), total)
+	}
+}
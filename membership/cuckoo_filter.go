@@ -2,13 +2,16 @@ package membership
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"math/rand"
-	"time"
 
 	"github.com/mrtkp9993/probdsgo/utils"
 )
 
+// ErrFilterFull is returned by Insert when a filter has no room for another item
+var ErrFilterFull = errors.New("filter is full")
+
 var LOAD_FACTOR_MAP = map[uint]float64{
 	2: 0.84,
 	4: 0.95,
@@ -31,19 +34,47 @@ type Bucket struct {
 	size         uint
 }
 
+// victimSlot holds a fingerprint evicted by an insertion that ran out of
+// relocations, together with one of its two buckets
+type victimSlot struct {
+	fingerprint byte
+	value       uint64
+	index       uint
+	used        bool
+}
+
+// WithSemiSorting stores buckets semi-sorted, packing four 8-bit fingerprints into
+// 28 bits. This saves one bit per entry at the same false positive rate, at the
+// cost of decoding a bucket on each access. Requires bucket size 4 and
+// FINGERPRINT_SIZE_8
+func WithSemiSorting() Option {
+	return func(o *options) {
+		o.semiSorted = true
+	}
+}
+
 // CuckooFilter represents a probabilistic data structure for membership testing
 type CuckooFilter struct {
-	buckets             []Bucket
+	buckets []Bucket
+	packed  *semiSortedTable
+	// values holds a bit-packed value for each fingerprint slot when valueBits > 0
+	values              []uint64
+	valueBits           uint
+	victim              victimSlot
 	hashFunc            *utils.Murmur3
 	fingerprintHashFunc *utils.Murmur3
 	rng                 *rand.Rand
+	// copySeed seeds the filters built by Merge, Intersect and Difference, so they
+	// do not draw from rng and change the relocations of this filter
+	copySeed int64
 
+	numBuckets      uint
 	bucketSize      uint
 	fingerprintSize uint
 	count           uint
 }
 
-func NewCuckooFilter(capacity uint, bucketSize uint, fingerprintSize FINGERPRINT_SIZE) (*CuckooFilter, error) {
+func NewCuckooFilter(capacity uint, bucketSize uint, fingerprintSize FINGERPRINT_SIZE, opts ...Option) (*CuckooFilter, error) {
 	if capacity < 1 {
 		return nil, errors.New("invalid capacity")
 	}
@@ -56,33 +87,52 @@ func NewCuckooFilter(capacity uint, bucketSize uint, fingerprintSize FINGERPRINT
 		return nil, errors.New("invalid bucket size, must be 2, 4, or 8")
 	}
 
+	options := newOptions(opts)
+
 	fpSize := uint(8)
 	if fingerprintSize == FINGERPRINT_SIZE_16 {
 		fpSize = 16
 	}
 
+	if options.semiSorted && (bucketSize != 4 || fpSize != 8) {
+		return nil, errors.New("semi-sorting requires bucket size 4 and 8-bit fingerprints")
+	}
+
 	loadFactor := LOAD_FACTOR_MAP[bucketSize]
 	numBuckets := uint(math.Ceil(float64(capacity) / (loadFactor * float64(bucketSize))))
 	numBuckets = getNextPowerOf2(numBuckets)
 
-	buckets := make([]Bucket, numBuckets)
-	for i := range buckets {
-		buckets[i] = Bucket{
-			fingerprints: make([]byte, bucketSize),
-			size:         0,
+	var buckets []Bucket
+	var packed *semiSortedTable
+	if options.semiSorted {
+		packed = newSemiSortedTable(numBuckets)
+	} else {
+		buckets = make([]Bucket, numBuckets)
+		for i := range buckets {
+			buckets[i] = Bucket{
+				fingerprints: make([]byte, bucketSize),
+				size:         0,
+			}
 		}
 	}
 
 	hashFunc := utils.NewMurmur3WithSeed(0)
 	fingerprintHashFunc := utils.NewMurmur3WithSeed(1)
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	rng := options.rand()
+	copySeed := options.seed
+	if !options.seeded {
+		copySeed = rng.Int63()
+	}
 
 	return &CuckooFilter{
 		buckets:             buckets,
+		packed:              packed,
 		hashFunc:            hashFunc,
 		fingerprintHashFunc: fingerprintHashFunc,
 		count:               0,
 		rng:                 rng,
+		copySeed:            copySeed,
+		numBuckets:          numBuckets,
 		bucketSize:          bucketSize,
 		fingerprintSize:     fpSize,
 	}, nil
@@ -117,41 +167,122 @@ func (cf *CuckooFilter) generateFingerprint(item []byte) byte {
 // getIndices returns the two possible bucket indices for an item
 func (cf *CuckooFilter) getIndices(item []byte, fingerprint byte) (uint, uint) {
 	hash1 := cf.hashFunc.Hash(item)
-	index1 := uint(hash1) & (uint(len(cf.buckets)) - 1)
+	index1 := uint(hash1) & (cf.numBuckets - 1)
 
-	hash2 := cf.fingerprintHashFunc.Hash([]byte{fingerprint})
-	index2 := index1 ^ (uint(hash2) & (uint(len(cf.buckets)) - 1))
+	return index1, cf.altIndex(index1, fingerprint)
+}
 
-	return index1, index2
+// altIndex returns the other bucket a fingerprint may be stored in
+func (cf *CuckooFilter) altIndex(index uint, fingerprint byte) uint {
+	hash := cf.fingerprintHashFunc.Hash([]byte{fingerprint})
+	return index ^ (uint(hash) & (cf.numBuckets - 1))
 }
 
-// Insert adds an item to the filter
+// Insert adds an item to the filter with multiset semantics: every call stores
+// another copy, counted by CountItem and removed one at a time by Delete. The two
+// buckets of an item hold at most 2*bucketSize copies
+// Returns error if the filter is full or the item has reached its copy limit
 func (cf *CuckooFilter) Insert(item []byte) error {
-	loadFactor := float64(cf.count) / (float64(len(cf.buckets)) * float64(cf.bucketSize))
-	maxLoadFactor := LOAD_FACTOR_MAP[cf.bucketSize]
-	if loadFactor >= maxLoadFactor {
-		return errors.New("filter is full")
+	return cf.insert(item, 0)
+}
+
+// InsertUnique adds an item to the filter with set semantics, doing nothing if the
+// item is already present. A single Delete then removes the item. An item whose
+// lookup is a false positive is not stored; it is reported present all the same,
+// but deleting it removes the colliding item instead
+// Returns error if the filter is full
+func (cf *CuckooFilter) InsertUnique(item []byte) error {
+	if cf.Lookup(item) {
+		return nil
 	}
 
+	return cf.insert(item, 0)
+}
+
+// CountItem returns the number of stored copies of an item. Copies of other items
+// with the same fingerprint and buckets are counted too, so this is an upper bound
+func (cf *CuckooFilter) CountItem(item []byte) uint {
 	fingerprint := cf.generateFingerprint(item)
 	i1, i2 := cf.getIndices(item, fingerprint)
 
-	// Try to insert into either bucket
-	if cf.buckets[i1].size < cf.bucketSize {
-		cf.buckets[i1].fingerprints[cf.buckets[i1].size] = fingerprint
-		cf.buckets[i1].size++
-		cf.count++
-		return nil
+	return cf.countFingerprint(fingerprint, i1, i2)
+}
+
+// countFingerprint returns the number of copies of a fingerprint in buckets i1 and i2
+func (cf *CuckooFilter) countFingerprint(fingerprint byte, i1, i2 uint) uint {
+	count := uint(0)
+	indices := []uint{i1, i2}
+	if i1 == i2 {
+		indices = indices[:1]
+	}
+	for _, index := range indices {
+		for position := uint(0); position < cf.bucketLen(index); position++ {
+			if cf.bucketFingerprint(index, position) == fingerprint {
+				count++
+			}
+		}
 	}
 
-	if cf.buckets[i2].size < cf.bucketSize {
-		cf.buckets[i2].fingerprints[cf.buckets[i2].size] = fingerprint
-		cf.buckets[i2].size++
+	if cf.victimMatches(fingerprint, i1, i2) {
+		count++
+	}
+	return count
+}
+
+// insert adds an item together with the value stored alongside its fingerprint
+func (cf *CuckooFilter) insert(item []byte, value uint64) error {
+	fingerprint := cf.generateFingerprint(item)
+	i1, i2 := cf.getIndices(item, fingerprint)
+
+	return cf.insertHashed(fingerprint, value, i1, i2)
+}
+
+// insertHashed adds an item given its fingerprint and buckets
+func (cf *CuckooFilter) insertHashed(fingerprint byte, value uint64, i1, i2 uint) error {
+	loadFactor := float64(cf.count) / (float64(cf.numBuckets) * float64(cf.bucketSize))
+	maxLoadFactor := LOAD_FACTOR_MAP[cf.bucketSize]
+	if loadFactor >= maxLoadFactor || cf.victim.used {
+		return ErrFilterFull
+	}
+
+	// Relocations cannot make room for more copies than both buckets hold
+	maxCopies := 2 * cf.bucketSize
+	if i1 == i2 {
+		maxCopies = cf.bucketSize
+	}
+	if cf.countFingerprint(fingerprint, i1, i2) >= maxCopies {
+		return errors.New("too many copies of item")
+	}
+
+	return cf.insertEntry(fingerprint, value, i1, i2)
+}
+
+// placeFingerprint stores a fingerprint in one of its two buckets without relocating others
+func (cf *CuckooFilter) placeFingerprint(fingerprint byte, i1, i2 uint) bool {
+	return cf.bucketAppend(i1, fingerprint, 0) || cf.bucketAppend(i2, fingerprint, 0)
+}
+
+// insertFingerprint stores a fingerprint in one of its two buckets, relocating
+// existing fingerprints when both are full. If no free slot is found within
+// MaxKicks relocations, the last evicted fingerprint is kept in the victim slot,
+// so no stored item is lost
+func (cf *CuckooFilter) insertFingerprint(fingerprint byte, i1, i2 uint) error {
+	return cf.insertEntry(fingerprint, 0, i1, i2)
+}
+
+// insertEntry inserts a fingerprint like insertFingerprint, moving its value with it
+func (cf *CuckooFilter) insertEntry(fingerprint byte, value uint64, i1, i2 uint) error {
+	if cf.victim.used {
+		return ErrFilterFull
+	}
+
+	// Try to insert into either bucket
+	if cf.bucketAppend(i1, fingerprint, value) || cf.bucketAppend(i2, fingerprint, value) {
 		cf.count++
 		return nil
 	}
 
-	currentFp := fingerprint
+	currentFp, currentValue := fingerprint, value
 	currentIndex := i1
 	if cf.rng.Intn(2) == 0 {
 		currentIndex = i2
@@ -159,17 +290,10 @@ func (cf *CuckooFilter) Insert(item []byte) error {
 
 	for range MaxKicks {
 		randPos := uint(cf.rng.Intn(int(cf.bucketSize)))
-		oldFp := cf.buckets[currentIndex].fingerprints[randPos]
-		cf.buckets[currentIndex].fingerprints[randPos] = currentFp
-
-		currentFp = oldFp
-
-		hash := cf.fingerprintHashFunc.Hash([]byte{currentFp})
-		alternateIndex := currentIndex ^ (uint(hash) & (uint(len(cf.buckets)) - 1))
+		currentFp, currentValue = cf.bucketReplace(currentIndex, randPos, currentFp, currentValue)
+		alternateIndex := cf.altIndex(currentIndex, currentFp)
 
-		if cf.buckets[alternateIndex].size < cf.bucketSize {
-			cf.buckets[alternateIndex].fingerprints[cf.buckets[alternateIndex].size] = currentFp
-			cf.buckets[alternateIndex].size++
+		if cf.bucketAppend(alternateIndex, currentFp, currentValue) {
 			cf.count++
 			return nil
 		}
@@ -177,7 +301,29 @@ func (cf *CuckooFilter) Insert(item []byte) error {
 		currentIndex = alternateIndex
 	}
 
-	return errors.New("maximum number of relocations reached")
+	// The new item is stored; the fingerprint left without a bucket belongs to
+	// currentIndex or its alternate
+	cf.victim = victimSlot{fingerprint: currentFp, value: currentValue, index: currentIndex, used: true}
+	cf.count++
+	return nil
+}
+
+// reinsertVictim tries to move the victim back into the buckets after a deletion.
+// The victim is already counted, so the count is left unchanged
+func (cf *CuckooFilter) reinsertVictim() {
+	if !cf.victim.used {
+		return
+	}
+
+	victim := cf.victim
+	cf.victim.used = false
+	cf.insertEntry(victim.fingerprint, victim.value, victim.index, cf.altIndex(victim.index, victim.fingerprint))
+	cf.count--
+}
+
+// victimMatches reports whether the victim is the given fingerprint stored for buckets i1 and i2
+func (cf *CuckooFilter) victimMatches(fingerprint byte, i1, i2 uint) bool {
+	return cf.victim.used && cf.victim.fingerprint == fingerprint && (cf.victim.index == i1 || cf.victim.index == i2)
 }
 
 // Lookup checks if an item might be in the filter
@@ -185,14 +331,37 @@ func (cf *CuckooFilter) Lookup(item []byte) bool {
 	fingerprint := cf.generateFingerprint(item)
 	i1, i2 := cf.getIndices(item, fingerprint)
 
-	for i := uint(0); i < cf.buckets[i1].size; i++ {
-		if cf.buckets[i1].fingerprints[i] == fingerprint {
-			return true
-		}
+	return cf.bucketContains(i1, fingerprint) || cf.bucketContains(i2, fingerprint) || cf.victimMatches(fingerprint, i1, i2)
+}
+
+// Delete removes one copy of an item. After Insert it undoes one insertion; after
+// InsertUnique it removes the item. Deleting an item that was never inserted may
+// remove another item with the same fingerprint and buckets
+// Returns false if the item is not present
+func (cf *CuckooFilter) Delete(item []byte) bool {
+	fingerprint := cf.generateFingerprint(item)
+	i1, i2 := cf.getIndices(item, fingerprint)
+
+	if cf.victimMatches(fingerprint, i1, i2) {
+		cf.victim.used = false
+		cf.count--
+		return true
 	}
 
-	for i := uint(0); i < cf.buckets[i2].size; i++ {
-		if cf.buckets[i2].fingerprints[i] == fingerprint {
+	if cf.deleteFingerprint(fingerprint, i1) || cf.deleteFingerprint(fingerprint, i2) {
+		cf.reinsertVictim()
+		return true
+	}
+
+	return false
+}
+
+// deleteFingerprint removes one copy of a fingerprint from a bucket
+func (cf *CuckooFilter) deleteFingerprint(fingerprint byte, index uint) bool {
+	for position := uint(0); position < cf.bucketLen(index); position++ {
+		if cf.bucketFingerprint(index, position) == fingerprint {
+			cf.bucketRemove(index, position)
+			cf.count--
 			return true
 		}
 	}
@@ -200,33 +369,124 @@ func (cf *CuckooFilter) Lookup(item []byte) bool {
 	return false
 }
 
-func (cf *CuckooFilter) Delete(item []byte) bool {
-	fingerprint := cf.generateFingerprint(item)
-	i1, i2 := cf.getIndices(item, fingerprint)
+// bucketLen returns the number of fingerprints stored in a bucket
+func (cf *CuckooFilter) bucketLen(index uint) uint {
+	if cf.packed != nil {
+		return semiSortedSize(cf.packed.read(index))
+	}
+	return cf.buckets[index].size
+}
 
-	for i := uint(0); i < cf.buckets[i1].size; i++ {
-		if cf.buckets[i1].fingerprints[i] == fingerprint {
-			if i < cf.buckets[i1].size-1 {
-				cf.buckets[i1].fingerprints[i] = cf.buckets[i1].fingerprints[cf.buckets[i1].size-1]
-			}
-			cf.buckets[i1].size--
-			cf.count--
+// bucketFingerprint returns the fingerprint at a position of a bucket
+func (cf *CuckooFilter) bucketFingerprint(index, position uint) byte {
+	if cf.packed != nil {
+		return cf.packed.read(index)[position]
+	}
+	return cf.buckets[index].fingerprints[position]
+}
+
+// bucketContains reports whether a bucket holds the fingerprint
+func (cf *CuckooFilter) bucketContains(index uint, fingerprint byte) bool {
+	if cf.packed != nil {
+		fingerprints := cf.packed.read(index)
+		return fingerprints[0] == fingerprint || fingerprints[1] == fingerprint ||
+			fingerprints[2] == fingerprint || fingerprints[3] == fingerprint
+	}
+
+	bucket := &cf.buckets[index]
+	for i := uint(0); i < bucket.size; i++ {
+		if bucket.fingerprints[i] == fingerprint {
 			return true
 		}
 	}
+	return false
+}
 
-	for i := uint(0); i < cf.buckets[i2].size; i++ {
-		if cf.buckets[i2].fingerprints[i] == fingerprint {
-			if i < cf.buckets[i2].size-1 {
-				cf.buckets[i2].fingerprints[i] = cf.buckets[i2].fingerprints[cf.buckets[i2].size-1]
-			}
-			cf.buckets[i2].size--
-			cf.count--
-			return true
+// bucketAppend stores a fingerprint and its value in a free slot of a bucket, if there is one
+func (cf *CuckooFilter) bucketAppend(index uint, fingerprint byte, value uint64) bool {
+	if cf.packed != nil {
+		fingerprints := cf.packed.read(index)
+		if fingerprints[3] != 0 {
+			return false
 		}
+		fingerprints[3] = fingerprint
+		cf.packed.write(index, fingerprints)
+		return true
 	}
 
-	return false
+	bucket := &cf.buckets[index]
+	if bucket.size == cf.bucketSize {
+		return false
+	}
+	bucket.fingerprints[bucket.size] = fingerprint
+	cf.setSlotValue(index, bucket.size, value)
+	bucket.size++
+	return true
+}
+
+// bucketReplace swaps the entry at a position of a full bucket and returns the old one
+func (cf *CuckooFilter) bucketReplace(index, position uint, fingerprint byte, value uint64) (byte, uint64) {
+	if cf.packed != nil {
+		fingerprints := cf.packed.read(index)
+		old := fingerprints[position]
+		fingerprints[position] = fingerprint
+		cf.packed.write(index, fingerprints)
+		return old, 0
+	}
+
+	old, oldValue := cf.buckets[index].fingerprints[position], cf.slotValue(index, position)
+	cf.buckets[index].fingerprints[position] = fingerprint
+	cf.setSlotValue(index, position, value)
+	return old, oldValue
+}
+
+// bucketRemove deletes the fingerprint at a position of a bucket. Fingerprints
+// before the position keep their positions
+func (cf *CuckooFilter) bucketRemove(index, position uint) {
+	if cf.packed != nil {
+		fingerprints := cf.packed.read(index)
+		fingerprints[position] = 0
+		cf.packed.write(index, fingerprints)
+		return
+	}
+
+	bucket := &cf.buckets[index]
+	bucket.fingerprints[position] = bucket.fingerprints[bucket.size-1]
+	cf.setSlotValue(index, position, cf.slotValue(index, bucket.size-1))
+	bucket.size--
+}
+
+// slotValue returns the value stored with the fingerprint at a position of a bucket
+func (cf *CuckooFilter) slotValue(index, position uint) uint64 {
+	if cf.valueBits == 0 {
+		return 0
+	}
+
+	bit := uint64(index*cf.bucketSize+position) * uint64(cf.valueBits)
+	word, offset := bit/64, bit%64
+
+	value := cf.values[word] >> offset
+	if offset+uint64(cf.valueBits) > 64 {
+		value |= cf.values[word+1] << (64 - offset)
+	}
+	return value & valueMask(cf.valueBits)
+}
+
+// setSlotValue writes the value stored with the fingerprint at a position of a bucket
+func (cf *CuckooFilter) setSlotValue(index, position uint, value uint64) {
+	if cf.valueBits == 0 {
+		return
+	}
+
+	bit := uint64(index*cf.bucketSize+position) * uint64(cf.valueBits)
+	word, offset := bit/64, bit%64
+	mask := valueMask(cf.valueBits)
+
+	cf.values[word] = cf.values[word]&^(mask<<offset) | value<<offset
+	if offset+uint64(cf.valueBits) > 64 {
+		shift := 64 - offset
+		cf.values[word+1] = cf.values[word+1]&^(mask>>shift) | value>>shift
+	}
 }
 
 func (cf *CuckooFilter) Count() uint {
@@ -234,9 +494,184 @@ func (cf *CuckooFilter) Count() uint {
 }
 
 func (cf *CuckooFilter) LoadFactor() float64 {
-	return float64(cf.count) / (float64(len(cf.buckets)) * float64(cf.bucketSize))
+	return float64(cf.count) / (float64(cf.numBuckets) * float64(cf.bucketSize))
 }
 
 func (cf *CuckooFilter) Size() uint {
-	return uint(len(cf.buckets)) * cf.bucketSize
+	return cf.numBuckets * cf.bucketSize
+}
+
+// SizeInBits returns the number of bits used to store fingerprints
+func (cf *CuckooFilter) SizeInBits() uint {
+	if cf.packed != nil {
+		return cf.numBuckets * SEMI_SORTED_BUCKET_BITS
+	}
+	return cf.numBuckets * cf.bucketSize * 8
+}
+
+// cuckooEntry identifies a stored fingerprint by its value and unordered bucket pair,
+// which is the same in every compatible filter
+type cuckooEntry struct {
+	fingerprint byte
+	index       uint
+}
+
+// entryKey returns the entry of a fingerprint stored in bucket index
+func (cf *CuckooFilter) entryKey(fingerprint byte, index uint) cuckooEntry {
+	return cuckooEntry{fingerprint: fingerprint, index: min(index, cf.altIndex(index, fingerprint))}
+}
+
+// forEachEntry calls fn for every stored fingerprint and its bucket, including the victim
+func (cf *CuckooFilter) forEachEntry(fn func(fingerprint byte, index uint)) {
+	for index := uint(0); index < cf.numBuckets; index++ {
+		for position := uint(0); position < cf.bucketLen(index); position++ {
+			fn(cf.bucketFingerprint(index, position), index)
+		}
+	}
+
+	if cf.victim.used {
+		fn(cf.victim.fingerprint, cf.victim.index)
+	}
+}
+
+// entryCounts returns how many times each entry is stored
+func (cf *CuckooFilter) entryCounts() map[cuckooEntry]uint {
+	counts := make(map[cuckooEntry]uint, cf.count)
+	cf.forEachEntry(func(fingerprint byte, index uint) {
+		counts[cf.entryKey(fingerprint, index)]++
+	})
+	return counts
+}
+
+// emptyCopy returns an empty filter with the same parameters and layout
+func (cf *CuckooFilter) emptyCopy() *CuckooFilter {
+	result := &CuckooFilter{
+		hashFunc:            cf.hashFunc,
+		fingerprintHashFunc: cf.fingerprintHashFunc,
+		rng:                 rand.New(rand.NewSource(cf.copySeed)),
+		copySeed:            cf.copySeed,
+		numBuckets:          cf.numBuckets,
+		bucketSize:          cf.bucketSize,
+		fingerprintSize:     cf.fingerprintSize,
+	}
+
+	if cf.packed != nil {
+		result.packed = newSemiSortedTable(cf.numBuckets)
+	} else {
+		result.buckets = make([]Bucket, cf.numBuckets)
+		for i := range result.buckets {
+			result.buckets[i] = Bucket{fingerprints: make([]byte, cf.bucketSize)}
+		}
+	}
+
+	return result
+}
+
+// addEntries inserts the selected fingerprints of cf into result
+func (cf *CuckooFilter) addEntries(result *CuckooFilter, keep func(fingerprint byte, index uint) bool) error {
+	var err error
+	cf.forEachEntry(func(fingerprint byte, index uint) {
+		if err != nil || !keep(fingerprint, index) {
+			return
+		}
+		err = result.insertFingerprint(fingerprint, index, result.altIndex(index, fingerprint))
+	})
+	return err
+}
+
+// Merge returns a filter holding the fingerprints of both filters. The original
+// items are not needed: every fingerprint is re-inserted into its own buckets,
+// which are the same in both filters
+// Returns error if the filters are not compatible or the merged items do not fit
+func (cf *CuckooFilter) Merge(other *CuckooFilter) (*CuckooFilter, error) {
+	if err := checkCuckooCompatibility(cf, other); err != nil {
+		return nil, fmt.Errorf("cannot merge: %v", err)
+	}
+
+	maxItems := LOAD_FACTOR_MAP[cf.bucketSize] * float64(cf.Size())
+	if float64(cf.count+other.count) > maxItems {
+		return nil, fmt.Errorf("cannot merge: %d items exceed the capacity of %d", cf.count+other.count, uint(maxItems))
+	}
+
+	result := cf.emptyCopy()
+	all := func(byte, uint) bool { return true }
+	if err := cf.addEntries(result, all); err != nil {
+		return nil, fmt.Errorf("cannot merge: %v", err)
+	}
+	if err := other.addEntries(result, all); err != nil {
+		return nil, fmt.Errorf("cannot merge: %v", err)
+	}
+
+	return result, nil
+}
+
+// Intersect returns a filter holding the fingerprints stored in both filters, as a
+// multiset. It is an approximation: an item of one filter is kept whenever the other
+// holds the same fingerprint in the same buckets, so the intersection may have the
+// false positives of both filters
+// Returns error if the filters are not compatible
+func (cf *CuckooFilter) Intersect(other *CuckooFilter) (*CuckooFilter, error) {
+	if err := checkCuckooCompatibility(cf, other); err != nil {
+		return nil, fmt.Errorf("cannot intersect: %v", err)
+	}
+
+	counts := other.entryCounts()
+	result := cf.emptyCopy()
+	err := cf.addEntries(result, func(fingerprint byte, index uint) bool {
+		key := cf.entryKey(fingerprint, index)
+		if counts[key] == 0 {
+			return false
+		}
+		counts[key]--
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot intersect: %v", err)
+	}
+
+	return result, nil
+}
+
+// Difference returns a filter holding the fingerprints of cf that are not in other,
+// as a multiset. It is an approximation: an item of cf is dropped whenever other
+// holds the same fingerprint in the same buckets, so the difference can have false
+// negatives at the false positive rate of other
+// Returns error if the filters are not compatible
+func (cf *CuckooFilter) Difference(other *CuckooFilter) (*CuckooFilter, error) {
+	if err := checkCuckooCompatibility(cf, other); err != nil {
+		return nil, fmt.Errorf("cannot compute difference: %v", err)
+	}
+
+	counts := other.entryCounts()
+	result := cf.emptyCopy()
+	err := cf.addEntries(result, func(fingerprint byte, index uint) bool {
+		key := cf.entryKey(fingerprint, index)
+		if counts[key] == 0 {
+			return true
+		}
+		counts[key]--
+		return false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot compute difference: %v", err)
+	}
+
+	return result, nil
+}
+
+func checkCuckooCompatibility(cf1, cf2 *CuckooFilter) error {
+	if cf1.numBuckets != cf2.numBuckets || cf1.bucketSize != cf2.bucketSize {
+		return errors.New("cuckoo filters have different table sizes")
+	}
+	if cf1.fingerprintSize != cf2.fingerprintSize {
+		return errors.New("cuckoo filters have different fingerprint sizes")
+	}
+	if cf1.hashFunc.Seed() != cf2.hashFunc.Seed() || cf1.fingerprintHashFunc.Seed() != cf2.fingerprintHashFunc.Seed() {
+		return errors.New("cuckoo filters use different hash function seeds")
+	}
+	if cf1.valueBits != 0 || cf2.valueBits != 0 {
+		return errors.New("cuckoo filters store values")
+	}
+
+	return nil
 }
@@ -2,6 +2,8 @@ package membership
 
 import (
 	"fmt"
+	"math/rand"
+	"reflect"
 	"testing"
 )
 
@@ -186,3 +188,461 @@ func TestCuckooFilter_FullCapacity_16bit(t *testing.T) {
 		t.Errorf("Inserted more items than allowed by load factor: got %d, want <= %d", insertedCount, maxItems)
 	}
 }
+
+func TestCuckooFilter_NoFalseNegativesAfterFailure(t *testing.T) {
+	for _, bucketSize := range []uint{2, 4, 8} {
+		t.Run(fmt.Sprintf("bucket size %d", bucketSize), func(t *testing.T) {
+			cf, err := NewCuckooFilter(200, bucketSize, FINGERPRINT_SIZE_8)
+			if err != nil {
+				t.Fatalf("Failed to create CuckooFilter: %v", err)
+			}
+
+			// Bypass the load factor limit of Insert so the table runs out of relocations
+			var inserted [][]byte
+			failures := 0
+			for i := 0; i < 1000 && failures < 10; i++ {
+				item := []byte(fmt.Sprintf("item%d", i))
+				fingerprint := cf.generateFingerprint(item)
+				i1, i2 := cf.getIndices(item, fingerprint)
+				if err := cf.insertFingerprint(fingerprint, i1, i2); err != nil {
+					failures++
+					continue
+				}
+				inserted = append(inserted, item)
+			}
+
+			if failures == 0 {
+				t.Fatal("expected insertions to fail once the table is full")
+			}
+			if !cf.victim.used {
+				t.Error("expected the victim slot to be in use after a failure")
+			}
+			if cf.Count() != uint(len(inserted)) {
+				t.Errorf("Count() = %d, want %d", cf.Count(), len(inserted))
+			}
+
+			for _, item := range inserted {
+				if !cf.Lookup(item) {
+					t.Fatalf("Lookup() false negative for %s after a failed insertion", item)
+				}
+			}
+
+			// Deleting frees a slot for the victim, and the rest stay visible
+			for _, item := range inserted[:len(inserted)/2] {
+				if !cf.Delete(item) {
+					t.Fatalf("Delete() failed for %s", item)
+				}
+			}
+			if cf.victim.used {
+				t.Error("victim not reinserted after deletions")
+			}
+			for _, item := range inserted[len(inserted)/2:] {
+				if !cf.Lookup(item) {
+					t.Fatalf("Lookup() false negative for %s after deletions", item)
+				}
+			}
+		})
+	}
+}
+
+func TestNewCuckooFilter_SemiSorting(t *testing.T) {
+	if _, err := NewCuckooFilter(1000, 4, FINGERPRINT_SIZE_8, WithSemiSorting()); err != nil {
+		t.Errorf("NewCuckooFilter() with semi-sorting error = %v", err)
+	}
+
+	if _, err := NewCuckooFilter(1000, 2, FINGERPRINT_SIZE_8, WithSemiSorting()); err == nil {
+		t.Error("NewCuckooFilter() with semi-sorting and bucket size 2 should return error")
+	}
+
+	if _, err := NewCuckooFilter(1000, 4, FINGERPRINT_SIZE_16, WithSemiSorting()); err == nil {
+		t.Error("NewCuckooFilter() with semi-sorting and 16-bit fingerprints should return error")
+	}
+}
+
+func TestCuckooFilter_SemiSorting(t *testing.T) {
+	capacity := uint(10000)
+	plain, err := NewCuckooFilter(capacity, 4, FINGERPRINT_SIZE_8)
+	if err != nil {
+		t.Fatalf("Failed to create CuckooFilter: %v", err)
+	}
+	packed, err := NewCuckooFilter(capacity, 4, FINGERPRINT_SIZE_8, WithSemiSorting())
+	if err != nil {
+		t.Fatalf("Failed to create CuckooFilter: %v", err)
+	}
+
+	n := int(float64(capacity) * 0.9)
+	for i := 0; i < n; i++ {
+		item := []byte(fmt.Sprintf("item%d", i))
+		if err := packed.Insert(item); err != nil {
+			t.Fatalf("Insert() error = %v after %d items", err, i)
+		}
+		plain.Insert(item)
+	}
+
+	if packed.Count() != uint(n) {
+		t.Errorf("Count() = %d, want %d", packed.Count(), n)
+	}
+	if got, want := packed.SizeInBits(), plain.SizeInBits()*7/8; got != want {
+		t.Errorf("SizeInBits() = %d, want %d", got, want)
+	}
+
+	for i := 0; i < n; i++ {
+		if !packed.Lookup([]byte(fmt.Sprintf("item%d", i))) {
+			t.Fatalf("Lookup() false negative for item%d", i)
+		}
+	}
+
+	// Semi-sorting stores the same fingerprints, so the false positive rate is unchanged
+	trials := 100000
+	packedFalsePositives, plainFalsePositives := 0, 0
+	for i := 0; i < trials; i++ {
+		item := []byte(fmt.Sprintf("other%d", i))
+		if packed.Lookup(item) {
+			packedFalsePositives++
+		}
+		if plain.Lookup(item) {
+			plainFalsePositives++
+		}
+	}
+	if packedFalsePositives > plainFalsePositives*12/10+20 {
+		t.Errorf("false positives = %d, want close to %d of the byte layout", packedFalsePositives, plainFalsePositives)
+	}
+
+	for i := 0; i < n; i += 2 {
+		if !packed.Delete([]byte(fmt.Sprintf("item%d", i))) {
+			t.Fatalf("Delete() failed for item%d", i)
+		}
+	}
+	for i := 1; i < n; i += 2 {
+		if !packed.Lookup([]byte(fmt.Sprintf("item%d", i))) {
+			t.Fatalf("Lookup() false negative for item%d after deletes", i)
+		}
+	}
+	if packed.Count() != uint(n/2) {
+		t.Errorf("Count() after deletes = %d, want %d", packed.Count(), n/2)
+	}
+}
+
+func TestCuckooFilter_Deterministic(t *testing.T) {
+	tests := []struct {
+		name string
+		opts func() []Option
+	}{
+		{
+			name: "seed",
+			opts: func() []Option { return []Option{WithSeed(42)} },
+		},
+		{
+			name: "rand source",
+			opts: func() []Option { return []Option{WithRandSource(rand.NewSource(42))} },
+		},
+		{
+			name: "seed with semi-sorting",
+			opts: func() []Option { return []Option{WithSeed(42), WithSemiSorting()} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := func() *CuckooFilter {
+				cf, err := NewCuckooFilter(1000, 4, FINGERPRINT_SIZE_8, tt.opts()...)
+				if err != nil {
+					t.Fatalf("Failed to create CuckooFilter: %v", err)
+				}
+
+				// Bypass the load factor limit of Insert so that insertions relocate
+				// fingerprints and finally fill the victim slot
+				for i := 0; i < 2000; i++ {
+					item := []byte(fmt.Sprintf("item%d", i))
+					fingerprint := cf.generateFingerprint(item)
+					i1, i2 := cf.getIndices(item, fingerprint)
+					cf.insertFingerprint(fingerprint, i1, i2)
+				}
+				for i := 0; i < 2000; i += 3 {
+					cf.Delete([]byte(fmt.Sprintf("item%d", i)))
+				}
+				return cf
+			}
+
+			first, second := run(), run()
+			if !reflect.DeepEqual(first.buckets, second.buckets) || !reflect.DeepEqual(first.packed, second.packed) {
+				t.Error("buckets differ between runs with the same seed")
+			}
+			if first.victim != second.victim || first.count != second.count {
+				t.Errorf("victim or count differ between runs: %+v %d, %+v %d", first.victim, first.count, second.victim, second.count)
+			}
+			if first.rng.Int63() != second.rng.Int63() {
+				t.Error("random generators differ between runs with the same seed")
+			}
+		})
+	}
+}
+
+func benchmarkCuckooFilterLookup(b *testing.B, opts ...Option) {
+	// A capacity that fills a power-of-two table at the maximum load factor
+	keys := makeKeys("item", benchmarkKeys)
+	cf, err := NewCuckooFilter(uint(float64(1<<18)*4*LOAD_FACTOR_MAP[4]), 4, FINGERPRINT_SIZE_8, opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, key := range keys {
+		if err := cf.Insert(key); err != nil {
+			break
+		}
+	}
+
+	falsePositives := 0
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if cf.Lookup([]byte(fmt.Sprintf("other%d", i))) {
+			falsePositives++
+		}
+	}
+
+	b.ReportMetric(float64(cf.SizeInBits())/float64(cf.Count()), "bits/key")
+	b.ReportMetric(float64(falsePositives)/float64(b.N), "fpr")
+}
+
+func BenchmarkCuckooFilter_Lookup(b *testing.B) {
+	benchmarkCuckooFilterLookup(b)
+}
+
+func BenchmarkCuckooFilter_LookupSemiSorted(b *testing.B) {
+	benchmarkCuckooFilterLookup(b, WithSemiSorting())
+}
+
+func newCuckooFilterWithRange(t *testing.T, capacity uint, from, to int, opts ...Option) *CuckooFilter {
+	t.Helper()
+	cf, err := NewCuckooFilter(capacity, 4, FINGERPRINT_SIZE_8, opts...)
+	if err != nil {
+		t.Fatalf("Failed to create CuckooFilter: %v", err)
+	}
+	for i := from; i < to; i++ {
+		if err := cf.Insert([]byte(fmt.Sprintf("item%d", i))); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+	return cf
+}
+
+func TestCuckooFilter_Merge(t *testing.T) {
+	a := newCuckooFilterWithRange(t, 10000, 0, 3000, WithSeed(1))
+	b := newCuckooFilterWithRange(t, 10000, 3000, 6000, WithSemiSorting())
+
+	merged, err := a.Merge(b)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if merged.Count() != 6000 {
+		t.Errorf("Count() after Merge = %d, want 6000", merged.Count())
+	}
+	for i := 0; i < 6000; i++ {
+		if !merged.Lookup([]byte(fmt.Sprintf("item%d", i))) {
+			t.Fatalf("Lookup() false negative for item%d after Merge", i)
+		}
+	}
+
+	// The merged filter supports deletion of the original items
+	for i := 0; i < 6000; i++ {
+		if !merged.Delete([]byte(fmt.Sprintf("item%d", i))) {
+			t.Fatalf("Delete() failed for item%d after Merge", i)
+		}
+	}
+	if merged.Count() != 0 {
+		t.Errorf("Count() after deleting all items = %d, want 0", merged.Count())
+	}
+	if a.Count() != 3000 || b.Count() != 3000 {
+		t.Errorf("Merge() modified its inputs: Count() = %d, %d", a.Count(), b.Count())
+	}
+}
+
+func TestCuckooFilter_MergeErrors(t *testing.T) {
+	// Both filters have 2048 slots, of which 95% can be used
+	a := newCuckooFilterWithRange(t, 1000, 0, 1000)
+	b := newCuckooFilterWithRange(t, 1000, 1000, 2000)
+	if _, err := a.Merge(b); err == nil {
+		t.Error("Merge() beyond the capacity should return error")
+	}
+
+	c := newCuckooFilterWithRange(t, 10000, 0, 10)
+	if _, err := a.Merge(c); err == nil {
+		t.Error("Merge() of filters with different table sizes should return error")
+	}
+	if _, err := a.Intersect(c); err == nil {
+		t.Error("Intersect() of filters with different table sizes should return error")
+	}
+	if _, err := a.Difference(c); err == nil {
+		t.Error("Difference() of filters with different table sizes should return error")
+	}
+
+	d, _ := NewCuckooFilter(1000, 2, FINGERPRINT_SIZE_8)
+	if _, err := a.Merge(d); err == nil {
+		t.Error("Merge() of filters with different bucket sizes should return error")
+	}
+}
+
+func TestCuckooFilter_IntersectDifference(t *testing.T) {
+	a := newCuckooFilterWithRange(t, 10000, 0, 6000)
+	b := newCuckooFilterWithRange(t, 10000, 4000, 10000)
+
+	intersection, err := a.Intersect(b)
+	if err != nil {
+		t.Fatalf("Intersect() error = %v", err)
+	}
+	for i := 4000; i < 6000; i++ {
+		if !intersection.Lookup([]byte(fmt.Sprintf("item%d", i))) {
+			t.Fatalf("Lookup() false negative for item%d in the intersection", i)
+		}
+	}
+	// Items of one filter colliding with items of the other are kept as well
+	if intersection.Count() < 2000 || intersection.Count() > 2100 {
+		t.Errorf("Count() of the intersection = %d, want about 2000", intersection.Count())
+	}
+
+	difference, err := a.Difference(b)
+	if err != nil {
+		t.Fatalf("Difference() error = %v", err)
+	}
+	if difference.Count()+intersection.Count() != a.Count() {
+		t.Errorf("Count() of difference and intersection = %d + %d, want %d", difference.Count(), intersection.Count(), a.Count())
+	}
+	missing := 0
+	for i := 0; i < 4000; i++ {
+		if !difference.Lookup([]byte(fmt.Sprintf("item%d", i))) {
+			missing++
+		}
+	}
+	if missing > 100 {
+		t.Errorf("%d of 4000 items missing from the difference, want few", missing)
+	}
+	stillThere := 0
+	for i := 4000; i < 6000; i++ {
+		if difference.Lookup([]byte(fmt.Sprintf("item%d", i))) {
+			stillThere++
+		}
+	}
+	if stillThere > 100 {
+		t.Errorf("%d of 2000 common items found in the difference, want few", stillThere)
+	}
+}
+
+func TestCuckooFilter_SetOperationsKeepRandomState(t *testing.T) {
+	// a and twin share a seed and an insert history; only a takes part in set operations
+	a := newCuckooFilterWithRange(t, 10000, 0, 5000, WithSeed(7))
+	twin := newCuckooFilterWithRange(t, 10000, 0, 5000, WithSeed(7))
+	other := newCuckooFilterWithRange(t, 10000, 4000, 6000)
+
+	if _, err := a.Merge(other); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if _, err := a.Intersect(other); err != nil {
+		t.Fatalf("Intersect() error = %v", err)
+	}
+	if _, err := a.Difference(other); err != nil {
+		t.Fatalf("Difference() error = %v", err)
+	}
+
+	// Filling both filters relocates fingerprints with their random generators
+	for i := 5000; i < 10000; i++ {
+		item := []byte(fmt.Sprintf("item%d", i))
+		a.Insert(item)
+		twin.Insert(item)
+	}
+	if !reflect.DeepEqual(a.buckets, twin.buckets) || a.victim != twin.victim {
+		t.Error("set operations changed the relocations of the receiver")
+	}
+}
+
+func TestCuckooFilter_RepeatedInsert(t *testing.T) {
+	for _, bucketSize := range []uint{2, 4, 8} {
+		t.Run(fmt.Sprintf("bucket size %d", bucketSize), func(t *testing.T) {
+			cf, err := NewCuckooFilter(1000, bucketSize, FINGERPRINT_SIZE_8)
+			if err != nil {
+				t.Fatalf("Failed to create CuckooFilter: %v", err)
+			}
+			for i := 0; i < 200; i++ {
+				cf.Insert([]byte(fmt.Sprintf("item%d", i)))
+			}
+
+			item := []byte("repeated")
+			fingerprint := cf.generateFingerprint(item)
+			i1, i2 := cf.getIndices(item, fingerprint)
+			maxCopies := 2 * bucketSize
+			if i1 == i2 {
+				maxCopies = bucketSize
+			}
+
+			// Every copy up to the limit is stored, then insertion fails cleanly
+			for i := uint(0); i < maxCopies; i++ {
+				if err := cf.Insert(item); err != nil {
+					t.Fatalf("Insert() of copy %d error = %v", i+1, err)
+				}
+			}
+			if got := cf.CountItem(item); got != maxCopies {
+				t.Errorf("CountItem() = %d, want %d", got, maxCopies)
+			}
+			if err := cf.Insert(item); err == nil {
+				t.Error("Insert() beyond the copy limit should return error")
+			}
+			if cf.victim.used {
+				t.Error("Insert() beyond the copy limit should not use the victim slot")
+			}
+
+			// Other items were relocated, not lost
+			for i := 0; i < 200; i++ {
+				if !cf.Lookup([]byte(fmt.Sprintf("item%d", i))) {
+					t.Fatalf("Lookup() false negative for item%d", i)
+				}
+			}
+
+			// Each Delete removes one copy
+			for i := maxCopies; i > 0; i-- {
+				if !cf.Delete(item) {
+					t.Fatalf("Delete() failed with %d copies left", i)
+				}
+				if got := cf.CountItem(item); got != i-1 {
+					t.Errorf("CountItem() = %d, want %d", got, i-1)
+				}
+			}
+			if cf.Lookup(item) || cf.Delete(item) {
+				t.Error("item still present after deleting every copy")
+			}
+		})
+	}
+}
+
+func TestCuckooFilter_InsertUnique(t *testing.T) {
+	cf, err := NewCuckooFilter(1000, 4, FINGERPRINT_SIZE_8)
+	if err != nil {
+		t.Fatalf("Failed to create CuckooFilter: %v", err)
+	}
+
+	item := []byte("unique")
+	for i := 0; i < 20; i++ {
+		if err := cf.InsertUnique(item); err != nil {
+			t.Fatalf("InsertUnique() error = %v", err)
+		}
+	}
+	if cf.CountItem(item) != 1 || cf.Count() != 1 {
+		t.Errorf("CountItem() = %d, Count() = %d after repeated InsertUnique, want 1", cf.CountItem(item), cf.Count())
+	}
+
+	// A single Delete removes the item
+	if !cf.Delete(item) {
+		t.Error("Delete() failed to remove the item")
+	}
+	if cf.Lookup(item) || cf.CountItem(item) != 0 {
+		t.Error("item still present after Delete")
+	}
+	if cf.Delete(item) {
+		t.Error("second Delete() should not find the item")
+	}
+
+	// Mixed with Insert, InsertUnique adds nothing to existing copies
+	cf.Insert(item)
+	cf.Insert(item)
+	cf.InsertUnique(item)
+	if cf.CountItem(item) != 2 {
+		t.Errorf("CountItem() = %d, want 2", cf.CountItem(item))
+	}
+}
@@ -0,0 +1,142 @@
+package membership
+
+import (
+	"errors"
+)
+
+// CuckooMap implements a cuckoo filter with payload: a compact map from keys to
+// small fixed-width values. Each fingerprint slot holds a bit-packed value that
+// moves with the fingerprint when it is relocated. Keys are not stored, so a lookup
+// may also return the values of other keys with the same fingerprint and buckets,
+// with the false positive rate of the underlying cuckoo filter
+type CuckooMap struct {
+	filter *CuckooFilter
+}
+
+// NewCuckooMap creates a new cuckoo map
+// capacity: maximum number of entries expected to be stored
+// bucketSize: number of entries per bucket, 2, 4 or 8
+// fingerprintSize: size of the stored fingerprints
+// valueBits: width of the stored values, between 1 and 64
+// opts: WithSeed or WithRandSource; semi-sorting is not supported
+func NewCuckooMap(capacity uint, bucketSize uint, fingerprintSize FINGERPRINT_SIZE, valueBits uint, opts ...Option) (*CuckooMap, error) {
+	if valueBits < 1 || valueBits > 64 {
+		return nil, errors.New("invalid value bits, must be between 1 and 64")
+	}
+
+	if newOptions(opts).semiSorted {
+		return nil, errors.New("semi-sorting is not supported with values")
+	}
+
+	cf, err := NewCuckooFilter(capacity, bucketSize, fingerprintSize, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	cf.valueBits = valueBits
+	cf.values = make([]uint64, (cf.numBuckets*bucketSize*valueBits+63)/64)
+
+	return &CuckooMap{filter: cf}, nil
+}
+
+// Insert adds a key with its value. Inserting a key again adds another entry;
+// use Update to change the value of a stored key
+// Returns error if the value does not fit in the value bits or the map is full
+func (cm *CuckooMap) Insert(key []byte, value uint64) error {
+	if err := validateInput(key); err != nil {
+		return err
+	}
+
+	if value > valueMask(cm.filter.valueBits) {
+		return errors.New("value does not fit in value bits")
+	}
+
+	return cm.filter.insert(key, value)
+}
+
+// Get returns the values of every entry matching the key
+// Returns false if the key is definitely not present
+func (cm *CuckooMap) Get(key []byte) ([]uint64, bool) {
+	cf := cm.filter
+	fingerprint := cf.generateFingerprint(key)
+	i1, i2 := cf.getIndices(key, fingerprint)
+
+	var values []uint64
+	indices := []uint{i1, i2}
+	if i1 == i2 {
+		indices = indices[:1]
+	}
+	for _, index := range indices {
+		for position := uint(0); position < cf.bucketLen(index); position++ {
+			if cf.bucketFingerprint(index, position) == fingerprint {
+				values = append(values, cf.slotValue(index, position))
+			}
+		}
+	}
+
+	if cf.victimMatches(fingerprint, i1, i2) {
+		values = append(values, cf.victim.value)
+	}
+
+	return values, len(values) > 0
+}
+
+// Update replaces the value of the first entry matching the key, which may belong
+// to another key with the same fingerprint and bucket
+// Returns error if the value does not fit in the value bits or the key is not present
+func (cm *CuckooMap) Update(key []byte, value uint64) error {
+	if err := validateInput(key); err != nil {
+		return err
+	}
+
+	if value > valueMask(cm.filter.valueBits) {
+		return errors.New("value does not fit in value bits")
+	}
+
+	cf := cm.filter
+	fingerprint := cf.generateFingerprint(key)
+	i1, i2 := cf.getIndices(key, fingerprint)
+
+	for _, index := range [2]uint{i1, i2} {
+		for position := uint(0); position < cf.bucketLen(index); position++ {
+			if cf.bucketFingerprint(index, position) == fingerprint {
+				cf.setSlotValue(index, position, value)
+				return nil
+			}
+		}
+	}
+
+	if cf.victimMatches(fingerprint, i1, i2) {
+		cf.victim.value = value
+		return nil
+	}
+
+	return errors.New("key not found")
+}
+
+// Delete removes the first entry matching the key. As in a cuckoo filter, this may
+// be the entry of another key with the same fingerprint and bucket
+// Returns false if the key is not present
+func (cm *CuckooMap) Delete(key []byte) bool {
+	return cm.filter.Delete(key)
+}
+
+// Count returns the number of entries in the map
+func (cm *CuckooMap) Count() uint {
+	return cm.filter.Count()
+}
+
+// LoadFactor returns the fraction of occupied entry slots
+func (cm *CuckooMap) LoadFactor() float64 {
+	return cm.filter.LoadFactor()
+}
+
+// Size returns the number of entry slots
+func (cm *CuckooMap) Size() uint {
+	return cm.filter.Size()
+}
+
+// SizeInBits returns the number of bits used to store fingerprints and values
+func (cm *CuckooMap) SizeInBits() uint {
+	return cm.filter.SizeInBits() + cm.filter.Size()*cm.filter.valueBits
+}
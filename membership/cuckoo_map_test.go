@@ -0,0 +1,175 @@
+package membership
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestNewCuckooMap(t *testing.T) {
+	tests := []struct {
+		name       string
+		capacity   uint
+		bucketSize uint
+		valueBits  uint
+		opts       []Option
+		wantErr    bool
+	}{
+		{name: "valid parameters", capacity: 1000, bucketSize: 4, valueBits: 12, wantErr: false},
+		{name: "64-bit values", capacity: 1000, bucketSize: 2, valueBits: 64, wantErr: false},
+		{name: "zero value bits", capacity: 1000, bucketSize: 4, valueBits: 0, wantErr: true},
+		{name: "too many value bits", capacity: 1000, bucketSize: 4, valueBits: 65, wantErr: true},
+		{name: "invalid bucket size", capacity: 1000, bucketSize: 3, valueBits: 8, wantErr: true},
+		{name: "semi-sorting", capacity: 1000, bucketSize: 4, valueBits: 8, opts: []Option{WithSemiSorting()}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCuckooMap(tt.capacity, tt.bucketSize, FINGERPRINT_SIZE_8, tt.valueBits, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewCuckooMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCuckooMap_BasicOperations(t *testing.T) {
+	cm, err := NewCuckooMap(1000, 4, FINGERPRINT_SIZE_8, 12)
+	if err != nil {
+		t.Fatalf("Failed to create CuckooMap: %v", err)
+	}
+
+	if err := cm.Insert([]byte("key1"), 42); err != nil {
+		t.Errorf("Insert() error = %v", err)
+	}
+	if err := cm.Insert([]byte("key2"), 1<<12); err == nil {
+		t.Error("Insert() with a value wider than the value bits should return error")
+	}
+	if err := cm.Insert(nil, 1); err == nil {
+		t.Error("Insert() of nil key should return error")
+	}
+
+	if values, ok := cm.Get([]byte("key1")); !ok || !slices.Contains(values, 42) {
+		t.Errorf("Get() = %v, %v, want to contain 42", values, ok)
+	}
+	if values, ok := cm.Get([]byte("key2")); ok {
+		t.Errorf("Get() of absent key = %v", values)
+	}
+
+	if err := cm.Update([]byte("key1"), 4095); err != nil {
+		t.Errorf("Update() error = %v", err)
+	}
+	if values, _ := cm.Get([]byte("key1")); !slices.Contains(values, 4095) || slices.Contains(values, 42) {
+		t.Errorf("Get() after Update = %v, want to contain 4095 only", values)
+	}
+	if err := cm.Update([]byte("key2"), 1); err == nil {
+		t.Error("Update() of absent key should return error")
+	}
+
+	if !cm.Delete([]byte("key1")) {
+		t.Error("Delete() failed to remove existing key")
+	}
+	if cm.Delete([]byte("key1")) {
+		t.Error("Delete() removed a key twice")
+	}
+	if cm.Count() != 0 {
+		t.Errorf("Count() = %d, want 0", cm.Count())
+	}
+}
+
+func TestCuckooMap_ValuesFollowRelocations(t *testing.T) {
+	for _, bucketSize := range []uint{2, 4, 8} {
+		t.Run(fmt.Sprintf("bucket size %d", bucketSize), func(t *testing.T) {
+			cm, err := NewCuckooMap(5000, bucketSize, FINGERPRINT_SIZE_8, 13, WithSeed(1))
+			if err != nil {
+				t.Fatalf("Failed to create CuckooMap: %v", err)
+			}
+
+			// Fill to the load factor so that most entries are relocated at least once
+			n := 0
+			for ; ; n++ {
+				if err := cm.Insert([]byte(fmt.Sprintf("key%d", n)), uint64(n%8192)); err != nil {
+					break
+				}
+			}
+			if cm.LoadFactor() < LOAD_FACTOR_MAP[bucketSize]-0.01 {
+				t.Errorf("LoadFactor() = %.3f, want about %.2f", cm.LoadFactor(), LOAD_FACTOR_MAP[bucketSize])
+			}
+
+			for i := 0; i < n; i++ {
+				if values, ok := cm.Get([]byte(fmt.Sprintf("key%d", i))); !ok || !slices.Contains(values, uint64(i%8192)) {
+					t.Fatalf("Get(key%d) = %v, %v, want to contain %d", i, values, ok, i%8192)
+				}
+			}
+
+			// Deleting moves values inside buckets; the remaining keys keep theirs,
+			// except where a deleted key removed the entry of a colliding key
+			for i := 0; i < n; i += 2 {
+				if !cm.Delete([]byte(fmt.Sprintf("key%d", i))) {
+					t.Fatalf("Delete() failed for key%d", i)
+				}
+			}
+			lost := 0
+			for i := 1; i < n; i += 2 {
+				values, ok := cm.Get([]byte(fmt.Sprintf("key%d", i)))
+				if !ok {
+					t.Fatalf("Get(key%d) after deletes found no entry", i)
+				}
+				if !slices.Contains(values, uint64(i%8192)) {
+					lost++
+				}
+			}
+			if lost > n/40 {
+				t.Errorf("%d of %d values lost after deletes, want few", lost, n/2)
+			}
+		})
+	}
+}
+
+func TestCuckooMap_VictimValue(t *testing.T) {
+	cm, err := NewCuckooMap(200, 4, FINGERPRINT_SIZE_8, 16, WithSeed(3))
+	if err != nil {
+		t.Fatalf("Failed to create CuckooMap: %v", err)
+	}
+	cf := cm.filter
+
+	// Bypass the load factor limit of Insert so that an entry ends in the victim slot
+	var inserted []int
+	for i := 0; i < 1000 && !cf.victim.used; i++ {
+		key := []byte(fmt.Sprintf("key%d", i))
+		fingerprint := cf.generateFingerprint(key)
+		i1, i2 := cf.getIndices(key, fingerprint)
+		if err := cf.insertEntry(fingerprint, uint64(i), i1, i2); err == nil {
+			inserted = append(inserted, i)
+		}
+	}
+	if !cf.victim.used {
+		t.Fatal("expected the victim slot to be in use")
+	}
+
+	for _, i := range inserted {
+		if values, ok := cm.Get([]byte(fmt.Sprintf("key%d", i))); !ok || !slices.Contains(values, uint64(i)) {
+			t.Fatalf("Get(key%d) = %v, %v, want to contain %d", i, values, ok, i)
+		}
+	}
+
+	// The victim is reinserted with its value once space is freed
+	for _, i := range inserted[:len(inserted)/2] {
+		cm.Delete([]byte(fmt.Sprintf("key%d", i)))
+	}
+	if cf.victim.used {
+		t.Error("victim not reinserted after deletions")
+	}
+	for _, i := range inserted[len(inserted)/2:] {
+		if values, ok := cm.Get([]byte(fmt.Sprintf("key%d", i))); !ok || !slices.Contains(values, uint64(i)) {
+			t.Fatalf("Get(key%d) after deletions = %v, %v, want to contain %d", i, values, ok, i)
+		}
+	}
+}
+
+func TestCuckooMap_SizeInBits(t *testing.T) {
+	cm, _ := NewCuckooMap(1000, 4, FINGERPRINT_SIZE_8, 5)
+	if got, want := cm.SizeInBits(), cm.Size()*(8+5); got != want {
+		t.Errorf("SizeInBits() = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,159 @@
+package membership
+
+import (
+	"errors"
+	"slices"
+)
+
+// DynamicCuckooFilter implements a dynamic cuckoo filter (Chen et al.), a list of
+// CuckooFilter segments of equal capacity. Items are inserted into the first
+// segment that is not full and a new segment is appended when all are full, so
+// the filter grows without rebuilding and reuses room freed by deletions. Lookups and deletions check every segment. Because all
+// segments share the same bucket count and hash functions, a fingerprint can be
+// moved from one segment to the same buckets of another, which Compact uses to
+// release segments emptied by deletions
+type DynamicCuckooFilter struct {
+	segments        []*CuckooFilter
+	segmentCapacity uint
+	bucketSize      uint
+	fingerprintSize FINGERPRINT_SIZE
+	opts            []Option
+}
+
+// NewDynamicCuckooFilter creates a new dynamic cuckoo filter with one segment
+// segmentCapacity: number of items each segment is sized for
+// bucketSize: number of fingerprints per bucket, 2, 4 or 8
+// fingerprintSize: size of the stored fingerprints
+// opts: options applied to every segment
+func NewDynamicCuckooFilter(segmentCapacity uint, bucketSize uint, fingerprintSize FINGERPRINT_SIZE, opts ...Option) (*DynamicCuckooFilter, error) {
+	segment, err := NewCuckooFilter(segmentCapacity, bucketSize, fingerprintSize, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DynamicCuckooFilter{
+		segments:        []*CuckooFilter{segment},
+		segmentCapacity: segmentCapacity,
+		bucketSize:      bucketSize,
+		fingerprintSize: fingerprintSize,
+		opts:            opts,
+	}, nil
+}
+
+// Insert adds an item to the first segment that is not full, appending a segment
+// when all are full
+// Returns error if the item has reached the copy limit of that segment
+func (dcf *DynamicCuckooFilter) Insert(item []byte) error {
+	for _, segment := range dcf.segments {
+		if err := segment.Insert(item); !errors.Is(err, ErrFilterFull) {
+			return err
+		}
+	}
+
+	segment, err := NewCuckooFilter(dcf.segmentCapacity, dcf.bucketSize, dcf.fingerprintSize, dcf.opts...)
+	if err != nil {
+		return err
+	}
+	dcf.segments = append(dcf.segments, segment)
+
+	return segment.Insert(item)
+}
+
+// Lookup checks if an item might be in any segment
+func (dcf *DynamicCuckooFilter) Lookup(item []byte) bool {
+	for _, segment := range dcf.segments {
+		if segment.Lookup(item) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delete removes one copy of an item from the first segment holding it
+func (dcf *DynamicCuckooFilter) Delete(item []byte) bool {
+	for _, segment := range dcf.segments {
+		if segment.Delete(item) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Compact moves the fingerprints of the emptiest segments into the free slots of
+// fuller ones and drops the segments left empty
+func (dcf *DynamicCuckooFilter) Compact() {
+	// Empty the sparsest segments first, into the fullest ones
+	slices.SortStableFunc(dcf.segments, func(a, b *CuckooFilter) int {
+		return int(a.count) - int(b.count)
+	})
+
+	for s, source := range dcf.segments {
+		for index := uint(0); index < source.numBuckets; index++ {
+			for position := uint(0); position < source.bucketLen(index); {
+				fingerprint := source.bucketFingerprint(index, position)
+				if !dcf.moveFingerprint(fingerprint, index, s) {
+					position++
+					continue
+				}
+
+				source.bucketRemove(index, position)
+				source.count--
+			}
+		}
+		source.reinsertVictim()
+	}
+
+	dcf.segments = slices.DeleteFunc(dcf.segments, func(segment *CuckooFilter) bool {
+		return segment.count == 0
+	})
+	if len(dcf.segments) == 0 {
+		segment, _ := NewCuckooFilter(dcf.segmentCapacity, dcf.bucketSize, dcf.fingerprintSize, dcf.opts...)
+		dcf.segments = []*CuckooFilter{segment}
+	}
+}
+
+// moveFingerprint places a fingerprint from bucket index of segment source into a
+// fuller segment, trying the fullest first
+func (dcf *DynamicCuckooFilter) moveFingerprint(fingerprint byte, index uint, source int) bool {
+	for t := len(dcf.segments) - 1; t > source; t-- {
+		target := dcf.segments[t]
+		if target.placeFingerprint(fingerprint, index, target.altIndex(index, fingerprint)) {
+			target.count++
+			return true
+		}
+	}
+
+	return false
+}
+
+// Count returns the number of items in all segments
+func (dcf *DynamicCuckooFilter) Count() uint {
+	count := uint(0)
+	for _, segment := range dcf.segments {
+		count += segment.Count()
+	}
+
+	return count
+}
+
+// Size returns the number of fingerprint slots in all segments
+func (dcf *DynamicCuckooFilter) Size() uint {
+	size := uint(0)
+	for _, segment := range dcf.segments {
+		size += segment.Size()
+	}
+
+	return size
+}
+
+// LoadFactor returns the fraction of occupied fingerprint slots
+func (dcf *DynamicCuckooFilter) LoadFactor() float64 {
+	return float64(dcf.Count()) / float64(dcf.Size())
+}
+
+// Segments returns the number of segments
+func (dcf *DynamicCuckooFilter) Segments() uint {
+	return uint(len(dcf.segments))
+}
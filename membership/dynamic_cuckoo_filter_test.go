@@ -0,0 +1,181 @@
+package membership
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestNewDynamicCuckooFilter(t *testing.T) {
+	if _, err := NewDynamicCuckooFilter(1000, 4, FINGERPRINT_SIZE_8); err != nil {
+		t.Errorf("NewDynamicCuckooFilter() error = %v", err)
+	}
+
+	if _, err := NewDynamicCuckooFilter(1000, 3, FINGERPRINT_SIZE_8); err == nil {
+		t.Error("NewDynamicCuckooFilter() with invalid bucket size should return error")
+	}
+
+	if _, err := NewDynamicCuckooFilter(0, 4, FINGERPRINT_SIZE_8); err == nil {
+		t.Error("NewDynamicCuckooFilter() with zero capacity should return error")
+	}
+}
+
+func TestDynamicCuckooFilter_Grow(t *testing.T) {
+	dcf, err := NewDynamicCuckooFilter(1000, 4, FINGERPRINT_SIZE_8)
+	if err != nil {
+		t.Fatalf("Failed to create DynamicCuckooFilter: %v", err)
+	}
+
+	// A single CuckooFilter of this capacity is full long before 10000 items
+	for i := 0; i < 10000; i++ {
+		if err := dcf.Insert([]byte(fmt.Sprintf("item%d", i))); err != nil {
+			t.Fatalf("Insert() error = %v after %d items", err, i)
+		}
+	}
+
+	if dcf.Count() != 10000 {
+		t.Errorf("Count() = %d, want 10000", dcf.Count())
+	}
+	if dcf.Segments() < 5 {
+		t.Errorf("Segments() = %d, want at least 5", dcf.Segments())
+	}
+
+	for i := 0; i < 10000; i++ {
+		if !dcf.Lookup([]byte(fmt.Sprintf("item%d", i))) {
+			t.Fatalf("Lookup() false negative for item%d", i)
+		}
+	}
+}
+
+func TestDynamicCuckooFilter_ReusesFreedRoom(t *testing.T) {
+	dcf, _ := NewDynamicCuckooFilter(1000, 4, FINGERPRINT_SIZE_8, WithSeed(1))
+	n := 0
+	for ; dcf.Segments() < 3; n++ {
+		dcf.Insert([]byte(fmt.Sprintf("item%d", n)))
+	}
+
+	// The first segment holds the first items, so deleting them frees its room
+	for i := 0; i < 1000; i++ {
+		dcf.Delete([]byte(fmt.Sprintf("item%d", i)))
+	}
+
+	// More items than the newest segment holds still fit once the first is refilled
+	for i := 0; i < 2500; i++ {
+		if err := dcf.Insert([]byte(fmt.Sprintf("new%d", i))); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	if dcf.Segments() != 3 {
+		t.Errorf("Segments() = %d, want 3", dcf.Segments())
+	}
+	if want := uint(n - 1000 + 2500); dcf.Count() != want {
+		t.Errorf("Count() = %d, want %d", dcf.Count(), want)
+	}
+}
+
+func TestDynamicCuckooFilter_CopyLimit(t *testing.T) {
+	dcf, err := NewDynamicCuckooFilter(1000, 4, FINGERPRINT_SIZE_8)
+	if err != nil {
+		t.Fatalf("Failed to create DynamicCuckooFilter: %v", err)
+	}
+
+	// Copies beyond the limit of a segment are rejected instead of growing the filter
+	item := []byte("repeated")
+	for i := 0; i < 100; i++ {
+		err := dcf.Insert(item)
+		if i < 8 && err != nil {
+			t.Fatalf("Insert() of copy %d error = %v", i+1, err)
+		}
+		if i >= 8 && (err == nil || errors.Is(err, ErrFilterFull)) {
+			t.Fatalf("Insert() of copy %d error = %v, want the copy limit error", i+1, err)
+		}
+	}
+
+	if dcf.Segments() != 1 {
+		t.Errorf("Segments() = %d, want 1", dcf.Segments())
+	}
+	if dcf.Count() != 8 {
+		t.Errorf("Count() = %d, want 8", dcf.Count())
+	}
+}
+
+func TestDynamicCuckooFilter_DeleteCompact(t *testing.T) {
+	dcf, _ := NewDynamicCuckooFilter(1000, 4, FINGERPRINT_SIZE_8, WithSeed(1))
+	for i := 0; i < 10000; i++ {
+		dcf.Insert([]byte(fmt.Sprintf("item%d", i)))
+	}
+	segments := dcf.Segments()
+
+	// Delete across all segments, keeping every fifth item
+	for i := 0; i < 10000; i++ {
+		if i%5 == 0 {
+			continue
+		}
+		if !dcf.Delete([]byte(fmt.Sprintf("item%d", i))) {
+			t.Fatalf("Delete() failed for item%d", i)
+		}
+	}
+
+	if dcf.Count() != 2000 {
+		t.Errorf("Count() after deletes = %d, want 2000", dcf.Count())
+	}
+
+	dcf.Compact()
+
+	if dcf.Segments() >= segments/2 {
+		t.Errorf("Segments() after Compact = %d, want fewer than %d", dcf.Segments(), segments/2)
+	}
+	if dcf.Count() != 2000 {
+		t.Errorf("Count() after Compact = %d, want 2000", dcf.Count())
+	}
+	if dcf.LoadFactor() < 0.4 {
+		t.Errorf("LoadFactor() after Compact = %.2f, want at least 0.4", dcf.LoadFactor())
+	}
+
+	for i := 0; i < 10000; i += 5 {
+		if !dcf.Lookup([]byte(fmt.Sprintf("item%d", i))) {
+			t.Fatalf("Lookup() false negative for item%d after Compact", i)
+		}
+	}
+
+	// Deleting everything leaves one empty segment
+	for i := 0; i < 10000; i += 5 {
+		dcf.Delete([]byte(fmt.Sprintf("item%d", i)))
+	}
+	dcf.Compact()
+	if dcf.Segments() != 1 || dcf.Count() != 0 {
+		t.Errorf("after deleting all items: Segments() = %d, Count() = %d", dcf.Segments(), dcf.Count())
+	}
+	if err := dcf.Insert([]byte("again")); err != nil || !dcf.Lookup([]byte("again")) {
+		t.Errorf("Insert() after Compact error = %v", err)
+	}
+}
+
+func TestDynamicCuckooFilter_Deterministic(t *testing.T) {
+	run := func() *DynamicCuckooFilter {
+		dcf, err := NewDynamicCuckooFilter(500, 4, FINGERPRINT_SIZE_8, WithSeed(7))
+		if err != nil {
+			t.Fatalf("Failed to create DynamicCuckooFilter: %v", err)
+		}
+		for i := 0; i < 3000; i++ {
+			dcf.Insert([]byte(fmt.Sprintf("item%d", i)))
+		}
+		for i := 0; i < 3000; i += 2 {
+			dcf.Delete([]byte(fmt.Sprintf("item%d", i)))
+		}
+		dcf.Compact()
+		return dcf
+	}
+
+	first, second := run(), run()
+	if first.Segments() != second.Segments() {
+		t.Fatalf("Segments() differ between runs with the same seed: %d != %d", first.Segments(), second.Segments())
+	}
+	for s := range first.segments {
+		if !reflect.DeepEqual(first.segments[s].buckets, second.segments[s].buckets) || first.segments[s].victim != second.segments[s].victim {
+			t.Fatalf("segment %d differs between runs with the same seed", s)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package membership
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// KeyEncoder appends the byte encoding of a key to buf and returns the extended buffer.
+// Equal keys must have equal encodings
+type KeyEncoder[K any] func(buf []byte, key K) []byte
+
+// KeyAppender is implemented by key types, such as structs, that encode themselves
+type KeyAppender interface {
+	AppendKey(buf []byte) []byte
+}
+
+// StringKey encodes a string key as its bytes
+func StringKey(buf []byte, key string) []byte {
+	return append(buf, key...)
+}
+
+// Uint64Key encodes an integer key as 8 little-endian bytes
+func Uint64Key(buf []byte, key uint64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, key)
+}
+
+// Int64Key encodes an integer key as 8 little-endian bytes in two's complement
+func Int64Key(buf []byte, key int64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, uint64(key))
+}
+
+// Bytes16Key encodes a 16-byte key, such as a UUID or a 128-bit hash, as itself
+func Bytes16Key(buf []byte, key [16]byte) []byte {
+	return append(buf, key[:]...)
+}
+
+// AppenderKey encodes a key with its AppendKey method
+func AppenderKey[K KeyAppender](buf []byte, key K) []byte {
+	return key.AppendKey(buf)
+}
+
+// EncodeKeys encodes typed keys for the structures built from a key set, such as
+// Xor8, BinaryFuse8, RibbonFilter or GolombCodedSet. All encodings share one buffer
+func EncodeKeys[K any](keys []K, encode KeyEncoder[K]) [][]byte {
+	var buf []byte
+	ends := make([]int, len(keys))
+	for i, key := range keys {
+		buf = encode(buf, key)
+		ends[i] = len(buf)
+	}
+
+	items := make([][]byte, len(keys))
+	start := 0
+	for i, end := range ends {
+		items[i] = buf[start:end:end]
+		start = end
+	}
+	return items
+}
+
+// Method sets of the membership structures, used to adapt them to Filter
+type (
+	adder interface {
+		Add(item []byte) error
+	}
+	inserter interface {
+		Insert(item []byte) error
+	}
+	countingInserter interface {
+		Insert(item []byte, n uint64) error
+	}
+	checker interface {
+		Contains(item []byte) (bool, error)
+	}
+	looker interface {
+		Lookup(item []byte) bool
+	}
+	deleter interface {
+		Delete(item []byte) bool
+	}
+	checkedDeleter interface {
+		Delete(item []byte) (bool, error)
+	}
+	countingRemover interface {
+		Remove(item []byte, n uint64) (uint64, error)
+	}
+)
+
+// Filter is a typed front-end for a membership structure over byte keys. Keys are
+// encoded into a buffer owned by the filter, so operations on string, integer and
+// fixed-size keys do not allocate once the buffer has grown. Like the structures
+// it wraps, a Filter is not safe for concurrent use
+type Filter[K any] struct {
+	encode   KeyEncoder[K]
+	buf      []byte
+	add      func(item []byte) error
+	contains func(item []byte) (bool, error)
+	remove   func(item []byte) (bool, error)
+}
+
+// NewFilter wraps a membership structure with a key encoder. Only the lookup is
+// required here; Add and Delete of a structure without insertion or deletion return
+// an error when called. The structures of this package provide:
+//   - Contains, Add and Delete: QuotientFilter, CountingQuotientFilter, CuckooFilter,
+//     DynamicCuckooFilter and VacuumFilter
+//   - Contains and Add: BloomFilter, BlockedBloomFilter, PartitionedBloomFilter,
+//     StableBloomFilter and AgingBloomFilter
+//   - Contains only, built from a key set with EncodeKeys: Xor8, BinaryFuse8,
+//     RibbonFilter and GolombCodedSet
+//
+// structure: any membership structure of this package with a byte-key lookup
+// (Contains or Lookup)
+// encode: StringKey, Uint64Key, Int64Key, Bytes16Key, AppenderKey or a custom encoder
+// Returns error if the structure has no byte-key lookup
+func NewFilter[K any](structure any, encode KeyEncoder[K]) (*Filter[K], error) {
+	if encode == nil {
+		return nil, errors.New("key encoder cannot be nil")
+	}
+
+	f := &Filter[K]{encode: encode}
+
+	switch s := structure.(type) {
+	case checker:
+		f.contains = s.Contains
+	case looker:
+		f.contains = func(item []byte) (bool, error) { return s.Lookup(item), nil }
+	default:
+		return nil, errors.New("structure does not support byte-key lookups")
+	}
+
+	switch s := structure.(type) {
+	case adder:
+		f.add = s.Add
+	case inserter:
+		f.add = s.Insert
+	case countingInserter:
+		f.add = func(item []byte) error { return s.Insert(item, 1) }
+	}
+
+	switch s := structure.(type) {
+	case deleter:
+		f.remove = func(item []byte) (bool, error) { return s.Delete(item), nil }
+	case checkedDeleter:
+		f.remove = s.Delete
+	case countingRemover:
+		f.remove = func(item []byte) (bool, error) {
+			removed, err := s.Remove(item, 1)
+			return removed > 0, err
+		}
+	}
+
+	return f, nil
+}
+
+// Add inserts a key into the underlying structure
+// Returns error if the structure does not support insertion or insertion fails
+func (f *Filter[K]) Add(key K) error {
+	if f.add == nil {
+		return errors.New("structure does not support insertion")
+	}
+
+	f.buf = f.encode(f.buf[:0], key)
+	return f.add(f.buf)
+}
+
+// Contains checks if a key might be in the underlying structure
+// Returns true if key might be present, false if definitely not present
+func (f *Filter[K]) Contains(key K) (bool, error) {
+	f.buf = f.encode(f.buf[:0], key)
+	return f.contains(f.buf)
+}
+
+// Delete removes one occurrence of a key from the underlying structure
+// Returns false if the key is not present
+// Returns error if the structure does not support deletion
+func (f *Filter[K]) Delete(key K) (bool, error) {
+	if f.remove == nil {
+		return false, errors.New("structure does not support deletion")
+	}
+
+	f.buf = f.encode(f.buf[:0], key)
+	return f.remove(f.buf)
+}
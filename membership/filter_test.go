@@ -0,0 +1,246 @@
+package membership
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// point is a struct key encoded through KeyAppender
+type point struct {
+	x, y int32
+}
+
+func (p point) AppendKey(buf []byte) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(p.x))
+	return binary.LittleEndian.AppendUint32(buf, uint32(p.y))
+}
+
+func TestNewFilter(t *testing.T) {
+	bf, _ := NewBloomFilter(100, 0.01)
+	if _, err := NewFilter[string](bf, nil); err == nil {
+		t.Error("NewFilter() with nil encoder should return error")
+	}
+
+	rf, _ := NewRangeFilter(100, 0.01, 16)
+	if _, err := NewFilter(rf, Uint64Key); err == nil {
+		t.Error("NewFilter() of a structure without byte-key lookups should return error")
+	}
+}
+
+func TestFilter_Structures(t *testing.T) {
+	keys := make([]uint64, 1000)
+	for i := range keys {
+		keys[i] = uint64(i) * 7919
+	}
+	static := EncodeKeys(keys, Uint64Key)
+
+	tests := []struct {
+		name      string
+		structure func() (any, error)
+		canAdd    bool
+		canDelete bool
+	}{
+		{name: "BloomFilter", structure: func() (any, error) { return NewBloomFilter(2000, 0.01) }, canAdd: true},
+		{name: "BlockedBloomFilter", structure: func() (any, error) { return NewBlockedBloomFilter(2000, 0.01) }, canAdd: true},
+		{name: "PartitionedBloomFilter", structure: func() (any, error) { return NewPartitionedBloomFilter(2000, 0.01) }, canAdd: true},
+		{name: "StableBloomFilter", structure: func() (any, error) { return NewStableBloomFilter(100000, 3, 0.01, WithSeed(1)) }, canAdd: true},
+		{name: "AgingBloomFilter", structure: func() (any, error) { return NewAgingBloomFilter(2000, 0.01, time.Hour, 2) }, canAdd: true},
+		{name: "QuotientFilter", structure: func() (any, error) { return NewQuotientFilter(2000, 0.01) }, canAdd: true, canDelete: true},
+		{name: "CountingQuotientFilter", structure: func() (any, error) { return NewCountingQuotientFilter(2000, 0.01) }, canAdd: true, canDelete: true},
+		{name: "CuckooFilter", structure: func() (any, error) { return NewCuckooFilter(2000, 4, FINGERPRINT_SIZE_8) }, canAdd: true, canDelete: true},
+		{name: "DynamicCuckooFilter", structure: func() (any, error) { return NewDynamicCuckooFilter(500, 4, FINGERPRINT_SIZE_8) }, canAdd: true, canDelete: true},
+		{name: "VacuumFilter", structure: func() (any, error) { return NewVacuumFilter(2000) }, canAdd: true, canDelete: true},
+		{name: "Xor8", structure: func() (any, error) { return NewXor8(static) }},
+		{name: "BinaryFuse8", structure: func() (any, error) { return NewBinaryFuse8(static, FUSE_ARITY_3) }},
+		{name: "RibbonFilter", structure: func() (any, error) { return NewRibbonFilter(static, 8) }},
+		{name: "GolombCodedSet", structure: func() (any, error) { return NewGolombCodedSet(static, 10, 1024, [16]byte{}) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			structure, err := tt.structure()
+			if err != nil {
+				t.Fatalf("Failed to create %s: %v", tt.name, err)
+			}
+			f, err := NewFilter(structure, Uint64Key)
+			if err != nil {
+				t.Fatalf("NewFilter() error = %v", err)
+			}
+
+			for _, key := range keys {
+				err := f.Add(key)
+				if tt.canAdd && err != nil {
+					t.Fatalf("Add(%d) error = %v", key, err)
+				}
+				if !tt.canAdd && err == nil {
+					t.Fatal("Add() should return error for a static structure")
+				}
+			}
+
+			for _, key := range keys {
+				if exists, err := f.Contains(key); err != nil || !exists {
+					t.Fatalf("Contains(%d) = %v, %v, want true", key, exists, err)
+				}
+			}
+
+			falsePositives := 0
+			for i := uint64(0); i < 1000; i++ {
+				if exists, _ := f.Contains(i*7919 + 1); exists {
+					falsePositives++
+				}
+			}
+			if falsePositives > 100 {
+				t.Errorf("%d false positives in 1000 lookups", falsePositives)
+			}
+
+			deleted, err := f.Delete(keys[0])
+			if tt.canDelete && (err != nil || !deleted) {
+				t.Errorf("Delete() = %v, %v, want true", deleted, err)
+			}
+			if !tt.canDelete && err == nil {
+				t.Error("Delete() should return error for a structure without deletion")
+			}
+		})
+	}
+}
+
+func TestFilter_Encoders(t *testing.T) {
+	names, _ := NewFilter(mustBloomFilter(t), StringKey)
+	names.Add("alpha")
+	if exists, _ := names.Contains("alpha"); !exists {
+		t.Error("Contains() failed to find a string key")
+	}
+
+	signed, _ := NewFilter(mustBloomFilter(t), Int64Key)
+	signed.Add(-42)
+	if exists, _ := signed.Contains(-42); !exists {
+		t.Error("Contains() failed to find a negative key")
+	}
+
+	ids, _ := NewFilter(mustBloomFilter(t), Bytes16Key)
+	ids.Add([16]byte{1, 2, 3})
+	if exists, _ := ids.Contains([16]byte{1, 2, 3}); !exists {
+		t.Error("Contains() failed to find a 16-byte key")
+	}
+
+	points, _ := NewFilter(mustBloomFilter(t), AppenderKey[point])
+	points.Add(point{x: 3, y: -4})
+	if exists, _ := points.Contains(point{x: 3, y: -4}); !exists {
+		t.Error("Contains() failed to find a struct key")
+	}
+	if exists, _ := points.Contains(point{x: -4, y: 3}); exists {
+		t.Error("Contains() found a struct key that was not added")
+	}
+}
+
+func TestEncodeKeys(t *testing.T) {
+	items := EncodeKeys([]string{"a", "bc", "def"}, StringKey)
+	if len(items) != 3 || string(items[0]) != "a" || string(items[1]) != "bc" || string(items[2]) != "def" {
+		t.Errorf("EncodeKeys() = %q", items)
+	}
+
+	// Appending to one encoding must not overwrite the next
+	_ = append(items[0], 'x')
+	if string(items[1]) != "bc" {
+		t.Errorf("EncodeKeys() encodings share capacity: %q", items)
+	}
+}
+
+// nopSet is a membership structure that does no work, to measure the overhead of Filter
+type nopSet struct{}
+
+func (nopSet) Add(item []byte) error              { return nil }
+func (nopSet) Contains(item []byte) (bool, error) { return len(item) > 0, nil }
+
+func TestFilter_ZeroAllocations(t *testing.T) {
+	names, _ := NewFilter(nopSet{}, StringKey)
+	integers, _ := NewFilter(nopSet{}, Uint64Key)
+	signed, _ := NewFilter(nopSet{}, Int64Key)
+	ids, _ := NewFilter(nopSet{}, Bytes16Key)
+	key := fmt.Sprintf("key%d", 12345)
+
+	tests := []struct {
+		name string
+		run  func()
+	}{
+		{name: "string", run: func() { names.Add(key); names.Contains(key) }},
+		{name: "uint64", run: func() { integers.Add(12345); integers.Contains(12345) }},
+		{name: "int64", run: func() { signed.Add(-12345); signed.Contains(-12345) }},
+		{name: "[16]byte", run: func() { ids.Add([16]byte{1}); ids.Contains([16]byte{1}) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if allocs := testing.AllocsPerRun(100, tt.run); allocs != 0 {
+				t.Errorf("%s keys allocate %.1f times per operation, want 0", tt.name, allocs)
+			}
+		})
+	}
+}
+
+func TestFilter_ContainsZeroAllocations(t *testing.T) {
+	bf, _ := NewBloomFilter(1000, 0.01)
+	cf, _ := NewCuckooFilter(1000, 4, FINGERPRINT_SIZE_8)
+	qf, _ := NewQuotientFilter(1000, 0.01)
+
+	tests := []struct {
+		name      string
+		structure any
+	}{
+		{name: "BloomFilter", structure: bf},
+		{name: "CuckooFilter", structure: cf},
+		{name: "QuotientFilter", structure: qf},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(tt.structure, Uint64Key)
+			if err != nil {
+				t.Fatalf("NewFilter() error = %v", err)
+			}
+			for i := uint64(0); i < 500; i++ {
+				if err := f.Add(i); err != nil {
+					t.Fatalf("Add() error = %v", err)
+				}
+			}
+
+			// Check both a present key and an absent one
+			key := uint64(0)
+			allocs := testing.AllocsPerRun(100, func() {
+				f.Contains(key % 1000)
+				key += 7
+			})
+			if allocs != 0 {
+				t.Errorf("Contains() allocates %.1f times per call, want 0", allocs)
+			}
+		})
+	}
+}
+
+func mustBloomFilter(t *testing.T) *BloomFilter {
+	t.Helper()
+	bf, err := NewBloomFilter(1000, 0.01)
+	if err != nil {
+		t.Fatalf("Failed to create BloomFilter: %v", err)
+	}
+	return bf
+}
+
+func BenchmarkFilter_ContainsUint64(b *testing.B) {
+	bf, err := NewBlockedBloomFilter(benchmarkKeys, 0.01)
+	if err != nil {
+		b.Fatal(err)
+	}
+	f, _ := NewFilter(bf, Uint64Key)
+	for i := uint64(0); i < benchmarkKeys; i++ {
+		f.Add(i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Contains(uint64(i % benchmarkKeys))
+	}
+}
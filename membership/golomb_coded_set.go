@@ -0,0 +1,311 @@
+package membership
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"slices"
+
+	"github.com/mrtkp9993/probdsgo/utils"
+)
+
+const (
+	// BIP158_P is the Golomb-Rice parameter of BIP-158 basic block filters
+	BIP158_P = 19
+
+	// BIP158_M is the inverse false positive rate of BIP-158 basic block filters
+	BIP158_M = 784931
+)
+
+// GolombCodedSet implements a Golomb-coded set. Items are hashed with SipHash-2-4
+// onto [0, N*M), the hashes are sorted and the differences between consecutive
+// hashes are stored with Golomb-Rice coding of parameter P. The set uses close to
+// the information-theoretic minimum of about P+1.5 bits per item and has a false
+// positive rate of 1/M. With P = BIP158_P and M = BIP158_M the encoding is
+// bit-compatible with Bitcoin BIP-158 block filters
+type GolombCodedSet struct {
+	n    uint64
+	p    uint8
+	m    uint64
+	hash *utils.SipHash
+	// data holds the Golomb-Rice coded differences, without the item count
+	data []byte
+}
+
+// NewGolombCodedSet builds a Golomb-coded set from the given items
+// p: Golomb-Rice parameter, between 1 and 32
+// m: inverse false positive rate
+// key: SipHash key
+// Duplicate items are ignored
+func NewGolombCodedSet(items [][]byte, p uint8, m uint64, key [16]byte) (*GolombCodedSet, error) {
+	if err := validateGolombParams(p, m); err != nil {
+		return nil, err
+	}
+
+	unique := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		if err := validateInput(item); err != nil {
+			return nil, err
+		}
+		unique[string(item)] = struct{}{}
+	}
+
+	gcs := &GolombCodedSet{
+		n:    uint64(len(unique)),
+		p:    p,
+		m:    m,
+		hash: utils.NewSipHash(key),
+	}
+
+	values := make([]uint64, 0, len(unique))
+	for item := range unique {
+		values = append(values, gcs.hashToRange([]byte(item)))
+	}
+	slices.Sort(values)
+
+	var writer bitWriter
+	last := uint64(0)
+	for _, value := range values {
+		delta := value - last
+		for q := delta >> p; q > 0; q-- {
+			writer.writeBit(1)
+		}
+		writer.writeBit(0)
+		writer.writeBits(delta, uint(p))
+		last = value
+	}
+	gcs.data = writer.bytes()
+
+	return gcs, nil
+}
+
+// NewBIP158Filter builds a BIP-158 basic block filter from the filter elements
+// As in BIP-158, empty elements such as empty output scripts are skipped, while
+// NewGolombCodedSet rejects them
+// blockHash: the block hash in internal byte order, whose first 16 bytes are the key
+func NewBIP158Filter(items [][]byte, blockHash [32]byte) (*GolombCodedSet, error) {
+	elements := make([][]byte, 0, len(items))
+	for _, item := range items {
+		if len(item) > 0 {
+			elements = append(elements, item)
+		}
+	}
+
+	return NewGolombCodedSet(elements, BIP158_P, BIP158_M, bip158Key(blockHash))
+}
+
+// NewGolombCodedSetFromBytes decodes a set serialized by MarshalBinary
+// p, m and key must match the ones the set was built with
+func NewGolombCodedSetFromBytes(data []byte, p uint8, m uint64, key [16]byte) (*GolombCodedSet, error) {
+	if err := validateGolombParams(p, m); err != nil {
+		return nil, err
+	}
+
+	n, size, err := readCompactSize(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GolombCodedSet{
+		n:    n,
+		p:    p,
+		m:    m,
+		hash: utils.NewSipHash(key),
+		data: append([]byte(nil), data[size:]...),
+	}, nil
+}
+
+// NewBIP158FilterFromBytes decodes a serialized BIP-158 basic block filter
+func NewBIP158FilterFromBytes(data []byte, blockHash [32]byte) (*GolombCodedSet, error) {
+	return NewGolombCodedSetFromBytes(data, BIP158_P, BIP158_M, bip158Key(blockHash))
+}
+
+func bip158Key(blockHash [32]byte) [16]byte {
+	var key [16]byte
+	copy(key[:], blockHash[:16])
+	return key
+}
+
+func validateGolombParams(p uint8, m uint64) error {
+	if p < 1 || p > 32 {
+		return errors.New("invalid golomb parameter, must be between 1 and 32")
+	}
+
+	if m < 1 {
+		return errors.New("invalid inverse false positive rate")
+	}
+
+	return nil
+}
+
+// hashToRange maps an item uniformly onto [0, N*M)
+func (gcs *GolombCodedSet) hashToRange(item []byte) uint64 {
+	hi, _ := bits.Mul64(gcs.hash.Hash(item), gcs.n*gcs.m)
+	return hi
+}
+
+// Contains checks if an item might be in the set
+// Returns true if item might be present, false if definitely not present
+func (gcs *GolombCodedSet) Contains(item []byte) (bool, error) {
+	return gcs.ContainsAny([][]byte{item})
+}
+
+// ContainsAny checks if any of the items might be in the set, decoding the set once
+// Returns true if an item might be present, false if none is present
+func (gcs *GolombCodedSet) ContainsAny(items [][]byte) (bool, error) {
+	targets := make([]uint64, len(items))
+	for i, item := range items {
+		if err := validateInput(item); err != nil {
+			return false, err
+		}
+		targets[i] = gcs.hashToRange(item)
+	}
+
+	if gcs.n == 0 || len(targets) == 0 {
+		return false, nil
+	}
+	slices.Sort(targets)
+
+	reader := bitReader{data: gcs.data}
+	value := uint64(0)
+	for range gcs.n {
+		delta, err := reader.readGolomb(gcs.p)
+		if err != nil {
+			return false, err
+		}
+		value += delta
+
+		for len(targets) > 0 && targets[0] < value {
+			targets = targets[1:]
+		}
+		if len(targets) == 0 {
+			return false, nil
+		}
+		if targets[0] == value {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Count returns the number of distinct items in the set
+func (gcs *GolombCodedSet) Count() uint {
+	return uint(gcs.n)
+}
+
+// MarshalBinary encodes the set as the item count in Bitcoin CompactSize format
+// followed by the coded differences, the BIP-158 filter serialization
+func (gcs *GolombCodedSet) MarshalBinary() ([]byte, error) {
+	data := appendCompactSize(nil, gcs.n)
+	return append(data, gcs.data...), nil
+}
+
+func appendCompactSize(data []byte, n uint64) []byte {
+	switch {
+	case n < 0xfd:
+		return append(data, byte(n))
+	case n <= 0xffff:
+		return binary.LittleEndian.AppendUint16(append(data, 0xfd), uint16(n))
+	case n <= 0xffffffff:
+		return binary.LittleEndian.AppendUint32(append(data, 0xfe), uint32(n))
+	default:
+		return binary.LittleEndian.AppendUint64(append(data, 0xff), n)
+	}
+}
+
+// readCompactSize returns the decoded value and the number of bytes it used
+func readCompactSize(data []byte) (uint64, int, error) {
+	if len(data) < 1 {
+		return 0, 0, errors.New("data too short for item count")
+	}
+
+	switch data[0] {
+	case 0xfd:
+		if len(data) < 3 {
+			return 0, 0, errors.New("data too short for item count")
+		}
+		return uint64(binary.LittleEndian.Uint16(data[1:])), 3, nil
+	case 0xfe:
+		if len(data) < 5 {
+			return 0, 0, errors.New("data too short for item count")
+		}
+		return uint64(binary.LittleEndian.Uint32(data[1:])), 5, nil
+	case 0xff:
+		if len(data) < 9 {
+			return 0, 0, errors.New("data too short for item count")
+		}
+		return binary.LittleEndian.Uint64(data[1:]), 9, nil
+	default:
+		return uint64(data[0]), 1, nil
+	}
+}
+
+// bitWriter appends bits most significant first
+type bitWriter struct {
+	data  []byte
+	count uint
+}
+
+func (w *bitWriter) writeBit(bit uint64) {
+	if w.count%8 == 0 {
+		w.data = append(w.data, 0)
+	}
+	if bit != 0 {
+		w.data[len(w.data)-1] |= 0x80 >> (w.count % 8)
+	}
+	w.count++
+}
+
+// writeBits writes the low n bits of value
+func (w *bitWriter) writeBits(value uint64, n uint) {
+	for i := n; i > 0; i-- {
+		w.writeBit((value >> (i - 1)) & 1)
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	return w.data
+}
+
+// bitReader reads bits most significant first
+type bitReader struct {
+	data     []byte
+	position uint
+}
+
+func (r *bitReader) readBit() (uint64, error) {
+	if r.position/8 >= uint(len(r.data)) {
+		return 0, errors.New("unexpected end of golomb coded data")
+	}
+
+	bit := (r.data[r.position/8] >> (7 - r.position%8)) & 1
+	r.position++
+	return uint64(bit), nil
+}
+
+// readGolomb reads one Golomb-Rice coded value: a unary quotient and p remainder bits
+func (r *bitReader) readGolomb(p uint8) (uint64, error) {
+	quotient := uint64(0)
+	for {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 0 {
+			break
+		}
+		quotient++
+	}
+
+	remainder := uint64(0)
+	for range p {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		remainder = remainder<<1 | bit
+	}
+
+	return quotient<<p | remainder, nil
+}
@@ -0,0 +1,369 @@
+package membership
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// blockHash decodes a block hash from its usual display order into internal byte order
+func blockHash(t *testing.T, display string) [32]byte {
+	t.Helper()
+
+	decoded, err := hex.DecodeString(display)
+	if err != nil || len(decoded) != 32 {
+		t.Fatalf("invalid block hash %s", display)
+	}
+	slices.Reverse(decoded)
+
+	var hash [32]byte
+	copy(hash[:], decoded)
+	return hash
+}
+
+// bip158Header chains a filter to the previous filter header as in BIP-157:
+// the double SHA-256 of the filter's double SHA-256 and the previous header
+func bip158Header(filter []byte, previous [32]byte) [32]byte {
+	filterHash := doubleSHA256(filter)
+	return doubleSHA256(append(filterHash[:], previous[:]...))
+}
+
+func doubleSHA256(data []byte) [32]byte {
+	first := sha256.Sum256(data)
+	return sha256.Sum256(first[:])
+}
+
+// Rows of the BIP-158 test suite (testnet-19.json). The elements are the output
+// scripts of each block, as the blocks spend no outputs. The filter headers are
+// checked as well, which ties each filter to the published chain of headers
+func TestBIP158Filter_Vectors(t *testing.T) {
+	tests := []struct {
+		name           string
+		blockHash      string
+		elements       []string
+		previousHeader string
+		filter         string
+		header         string
+	}{
+		{
+			name:           "block 0, genesis block",
+			blockHash:      "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
+			elements:       []string{"4104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac"},
+			previousHeader: "0000000000000000000000000000000000000000000000000000000000000000",
+			filter:         "019dfca8",
+			header:         "21584579b7eb08997773e5aeff3a7f932700042d0ed2a6129012b7d7ae81b750",
+		},
+		{
+			name:           "block 2",
+			blockHash:      "000000006c02c8ea6e4ff69651f7fcde348fb9d557a06e6957b65552002a7820",
+			elements:       []string{"21038a7f6ef1c8ca0c588aa53fa860128077c9e6c11e6830f4d7ee4e763a56b7718fac"},
+			previousHeader: "d7bdac13a59d745b1add0d2ce852f1a0442e8945fc1bf3848d3cbffd88c24fe1",
+			filter:         "0174a170",
+			header:         "186afd11ef2b5e7e3504f2e8cbf8df28a1fd251fe53d60dff8b1467d1b386cf0",
+		},
+		{
+			name:           "block 3",
+			blockHash:      "000000008b896e272758da5297bcd98fdc6d97c9b765ecec401e286dc1fdbe10",
+			elements:       []string{"2103f6d9ff4c12959445ca5549c811683bf9c88e637b222dd2e0311154c4c85cf423ac"},
+			previousHeader: "186afd11ef2b5e7e3504f2e8cbf8df28a1fd251fe53d60dff8b1467d1b386cf0",
+			filter:         "016cf7a0",
+			header:         "8d63aadf5ab7257cb6d2316a57b16f517bff1c6388f124ec4c04af1212729d2a",
+		},
+		{
+			// Every element of block 1414221 is skipped, so the key does not matter
+			name:           "block 1414221, empty data",
+			blockHash:      "0000000000000000000000000000000000000000000000000000000000000000",
+			elements:       []string{""},
+			previousHeader: "5e5e12d90693c8e936f01847859404c67482439681928353ca1296982042864e",
+			filter:         "00",
+			header:         "021e8882ef5a0ed932edeebbecfeda1d7ce528ec7b3daa27641acf1189d7b5dc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash := blockHash(t, tt.blockHash)
+			items := make([][]byte, len(tt.elements))
+			for i, element := range tt.elements {
+				items[i], _ = hex.DecodeString(element)
+			}
+
+			gcs, err := NewBIP158Filter(items, hash)
+			if err != nil {
+				t.Fatalf("NewBIP158Filter() error = %v", err)
+			}
+
+			data, _ := gcs.MarshalBinary()
+			if got := hex.EncodeToString(data); got != tt.filter {
+				t.Errorf("MarshalBinary() = %s, want %s", got, tt.filter)
+			}
+			if bip158Header(data, blockHash(t, tt.previousHeader)) != blockHash(t, tt.header) {
+				t.Errorf("filter header does not match %s", tt.header)
+			}
+
+			decoded, err := NewBIP158FilterFromBytes(data, hash)
+			if err != nil {
+				t.Fatalf("NewBIP158FilterFromBytes() error = %v", err)
+			}
+			for _, item := range items {
+				if exists, _ := decoded.Contains(item); len(item) > 0 && !exists {
+					t.Errorf("Contains() false negative for %x", item)
+				}
+			}
+		})
+	}
+}
+
+// Filters with several elements, which check the sorting and the delta coding,
+// against filters computed with the btcd implementation (btcutil/gcs/builder). The
+// elements are the output scripts of early mainnet blocks with a spend and the
+// script of the spent coinbase. These are cross-checks, not published vectors
+func TestBIP158Filter_ReferenceImplementation(t *testing.T) {
+	const (
+		coinbase9   = "410411db93e1dcdb8a016b49840f8c53bc1eb68a382e97b1482ecad7b148a6909a5cb2e0eaddfb84ccf9744464f82e160bfa9b8b64f9d4c03f999b8643f656b412a3ac"
+		coinbase170 = "4104d46c4968bde02899d2aa0963367c7a6ce34eec332b32e42e5f3407e052d64ac625da6f0718e7b302140434bd725706957c092db53805b821a85b23a7ac61725bac"
+	)
+
+	tests := []struct {
+		name      string
+		blockHash string
+		elements  []string
+		filter    string
+	}{
+		{
+			// The change output pays the spent script again, which is counted once
+			name:      "mainnet block 170",
+			blockHash: "00000000d1145790a8694403d4063f323d499e655c83426834d4ce2f8dd4a2ee",
+			elements: []string{
+				coinbase170,
+				"4104ae1a62fe09c5f51b13905f07f06b99a2f7159b2225f374cd378d71302fa28414e7aab37397f554a7df5f142c21c1b7303b8a0626f1baded5c72a704f7e6cd84cac",
+				coinbase9,
+				coinbase9,
+			},
+			filter: "0357e49590040c79b0",
+		},
+		{
+			name:      "mainnet block 187",
+			blockHash: "00000000b2cde2159116889837ecf300bd77d229d49b138c55366b54626e495d",
+			elements: []string{
+				"410435d66d6cef63a3461110c810975b8816308372b58274d88436a974b478d98d8d972f7233ea8a5242d151de9d4b1ac11a6f7f8460e8f9b146d97c7bad980cc5ceac",
+				"4104fe1b9ccf732e1f6b760c5ed3152388eeeadd4a073e621f741eb157e6a62e3547c8e939abbd6a513bf3a1fbe28f9ea85a4e64c526702435d726f7ff14da40bae4ac",
+				"4104baa9d36653155627c740b3409a734d4eaf5dcca9fb4f736622ee18efcf0aec2b758b2ec40db18fbae708f691edb2d4a2a3775eb413d16e2e3c0f8d4c69119fd1ac",
+			},
+			filter: "030ca8a75ac8e74870",
+		},
+		{
+			name:      "mainnet block 221",
+			blockHash: "0000000066356691a4353dd8bdc2c60da20d68ad34fff93d8839a133b2a6d42a",
+			elements: []string{
+				"4104b089ed9912db1edb2e7338154e2ba9c4eaac2ea1b9f95600005aac1fde07315ef3c16125cc387373232ac6fa6453f2046e317e2295321a58f244d6d2692d597eac",
+				"4104ea0d6650c8305f1213a89c65fc8f4343a5dac8e985c869e51d3aa02879b57c60cff49fcb99314d02dfc612d654e4333150ef61fa569c1c66415602cae387baf7ac",
+				"410401518fa1d1e1e3e162852d68d9be1c0abad5e3d6297ec95f1f91b909dc1afe616d6876f92918451ca387c4387609ae1a895007096195a824baf9c38ea98c09c3ac",
+			},
+			filter: "0364c0e7869accce08",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := make([][]byte, len(tt.elements))
+			for i, element := range tt.elements {
+				items[i], _ = hex.DecodeString(element)
+			}
+
+			gcs, err := NewBIP158Filter(items, blockHash(t, tt.blockHash))
+			if err != nil {
+				t.Fatalf("NewBIP158Filter() error = %v", err)
+			}
+
+			data, _ := gcs.MarshalBinary()
+			if got := hex.EncodeToString(data); got != tt.filter {
+				t.Errorf("MarshalBinary() = %s, want %s", got, tt.filter)
+			}
+		})
+	}
+
+	// 1000 elements, compared by length, leading bytes and SHA-256 digest
+	items := make([][]byte, 1000)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("element%d", i))
+	}
+
+	gcs, err := NewBIP158Filter(items, blockHash(t, "00000000d1145790a8694403d4063f323d499e655c83426834d4ce2f8dd4a2ee"))
+	if err != nil {
+		t.Fatalf("NewBIP158Filter() error = %v", err)
+	}
+
+	data, _ := gcs.MarshalBinary()
+	if len(data) != 2634 {
+		t.Errorf("MarshalBinary() length = %d, want 2634", len(data))
+	}
+	if got := hex.EncodeToString(data[:16]); got != "fde803114f8fb992df0e89322ffb3878" {
+		t.Errorf("MarshalBinary() starts with %s, want fde803114f8fb992df0e89322ffb3878", got)
+	}
+	digest := sha256.Sum256(data)
+	if got := hex.EncodeToString(digest[:]); got != "6d832155dfc8a5ff5016a592f39bc71556113d7820cb075d04f528fc642ede25" {
+		t.Errorf("SHA-256 of MarshalBinary() = %s, want 6d832155dfc8a5ff5016a592f39bc71556113d7820cb075d04f528fc642ede25", got)
+	}
+}
+
+func TestBIP158Filter_SkipsEmptyElements(t *testing.T) {
+	genesis := blockHash(t, "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943")
+	script, _ := hex.DecodeString("4104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac")
+
+	gcs, err := NewBIP158Filter([][]byte{{}, script, nil}, genesis)
+	if err != nil {
+		t.Fatalf("NewBIP158Filter() error = %v", err)
+	}
+	if data, _ := gcs.MarshalBinary(); hex.EncodeToString(data) != "019dfca8" {
+		t.Errorf("MarshalBinary() = %x, want the genesis filter 019dfca8", data)
+	}
+
+	if _, err := NewGolombCodedSet([][]byte{{}}, BIP158_P, BIP158_M, [16]byte{}); err == nil {
+		t.Error("NewGolombCodedSet() of an empty item should return error")
+	}
+}
+
+func TestNewGolombCodedSet(t *testing.T) {
+	var key [16]byte
+	tests := []struct {
+		name    string
+		items   [][]byte
+		p       uint8
+		m       uint64
+		wantErr bool
+	}{
+		{
+			name:    "valid items",
+			items:   makeKeys("item", 10),
+			p:       19,
+			m:       784931,
+			wantErr: false,
+		},
+		{
+			name:    "invalid p",
+			items:   makeKeys("item", 10),
+			p:       0,
+			m:       784931,
+			wantErr: true,
+		},
+		{
+			name:    "invalid m",
+			items:   makeKeys("item", 10),
+			p:       19,
+			m:       0,
+			wantErr: true,
+		},
+		{
+			name:    "empty item",
+			items:   [][]byte{{}},
+			p:       19,
+			m:       784931,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewGolombCodedSet(tt.items, tt.p, tt.m, key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewGolombCodedSet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGolombCodedSet_Contains(t *testing.T) {
+	key := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	items := append(makeKeys("item", 2000), makeKeys("item", 100)...)
+
+	// P = log2(M) is optimal, giving about P+1.5 bits per item
+	gcs, err := NewGolombCodedSet(items, 6, 64, key)
+	if err != nil {
+		t.Fatalf("Failed to create GolombCodedSet: %v", err)
+	}
+
+	if gcs.Count() != 2000 {
+		t.Errorf("Count() = %d, want 2000", gcs.Count())
+	}
+
+	for i := 0; i < 2000; i += 7 {
+		if exists, _ := gcs.Contains(items[i]); !exists {
+			t.Fatalf("Contains() false negative for %s", items[i])
+		}
+	}
+
+	falsePositives := 0
+	trials := 5000
+	for i := 0; i < trials; i++ {
+		if exists, _ := gcs.Contains([]byte(fmt.Sprintf("other%d", i))); exists {
+			falsePositives++
+		}
+	}
+
+	rate := float64(falsePositives) / float64(trials)
+	if rate > 1.5/64 {
+		t.Errorf("False positive rate too high: got %.5f, want about 1/64", rate)
+	}
+
+	data, _ := gcs.MarshalBinary()
+	bitsPerItem := float64(8*len(data)) / 2000
+	if bitsPerItem > 8 {
+		t.Errorf("Bits per item too high: got %.2f, want about 7.5", bitsPerItem)
+	}
+
+	if exists, _ := gcs.ContainsAny([][]byte{[]byte("other1"), items[500], []byte("other2")}); !exists {
+		t.Error("ContainsAny() did not find a member")
+	}
+	if exists, _ := gcs.ContainsAny(nil); exists {
+		t.Error("ContainsAny() of no items = true, want false")
+	}
+
+	if _, err := gcs.Contains(nil); err == nil {
+		t.Error("Contains() of nil item should return error")
+	}
+}
+
+func TestGolombCodedSet_Serialization(t *testing.T) {
+	var key [16]byte
+	for _, n := range []int{0, 1, 300, 70000} {
+		t.Run(fmt.Sprintf("%d items", n), func(t *testing.T) {
+			items := makeKeys("item", n)
+			gcs, err := NewGolombCodedSet(items, 8, 256, key)
+			if err != nil {
+				t.Fatalf("Failed to create GolombCodedSet: %v", err)
+			}
+
+			data, _ := gcs.MarshalBinary()
+			decoded, err := NewGolombCodedSetFromBytes(data, 8, 256, key)
+			if err != nil {
+				t.Fatalf("NewGolombCodedSetFromBytes() error = %v", err)
+			}
+
+			again, _ := decoded.MarshalBinary()
+			if !bytes.Equal(data, again) {
+				t.Error("MarshalBinary() after decoding differs from original")
+			}
+			if decoded.Count() != uint(n) {
+				t.Errorf("Count() = %d, want %d", decoded.Count(), n)
+			}
+			for i := 0; i < n; i += n/8 + 1 {
+				if exists, _ := decoded.Contains(items[i]); !exists {
+					t.Fatalf("Contains() false negative for %s", items[i])
+				}
+			}
+		})
+	}
+
+	if _, err := NewGolombCodedSetFromBytes([]byte{0xfe, 1}, 8, 256, key); err == nil {
+		t.Error("NewGolombCodedSetFromBytes() of truncated count should return error")
+	}
+
+	truncated, _ := NewGolombCodedSetFromBytes([]byte{5, 0xff}, 8, 256, key)
+	if _, err := truncated.Contains([]byte("item")); err == nil {
+		t.Error("Contains() on truncated data should return error")
+	}
+}
@@ -0,0 +1,9 @@
+package membership
+
+import "github.com/mrtkp9993/probdsgo/utils"
+
+// hash64 returns a 64-bit hash of the item built from two independently seeded
+// Murmur3 hashes, for structures that need more than 32 bits of fingerprint
+func hash64(item []byte) uint64 {
+	return uint64(utils.Murmur3_32(item, 0))<<32 | uint64(utils.Murmur3_32(item, 1))
+}
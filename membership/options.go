@@ -0,0 +1,56 @@
+package membership
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Option configures optional behaviour of a data structure
+// Options that do not apply to a structure are ignored
+type Option func(*options)
+
+type options struct {
+	semiSorted bool
+	seed       int64
+	seeded     bool
+	source     rand.Source
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithSeed seeds the random choices of a randomized structure, so that the same
+// seed and the same sequence of operations produce identical internal state
+func WithSeed(seed int64) Option {
+	return func(o *options) {
+		o.seed = seed
+		o.seeded = true
+		o.source = nil
+	}
+}
+
+// WithRandSource sets the source of the random choices of a randomized structure.
+// The source is used directly, so it must not be shared between goroutines
+func WithRandSource(src rand.Source) Option {
+	return func(o *options) {
+		o.source = src
+		o.seeded = false
+	}
+}
+
+// rand returns the random generator selected by the options, seeded from the
+// clock when neither a seed nor a source was given
+func (o options) rand() *rand.Rand {
+	if o.source != nil {
+		return rand.New(o.source)
+	}
+	if o.seeded {
+		return rand.New(rand.NewSource(o.seed))
+	}
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
@@ -0,0 +1,208 @@
+package membership
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/mrtkp9993/probdsgo/utils"
+)
+
+// PartitionedBloomFilter implements a partitioned Bloom filter, where the bit array
+// is split into k equal slices and each hash function sets exactly one bit in its
+// own slice. Every item therefore sets k distinct bits, which makes the false
+// positive rate more uniform than that of a BloomFilter with the same m and k
+type PartitionedBloomFilter struct {
+	bitArray      []bool
+	bitCount      uint
+	sliceSize     uint
+	hashFuncCount uint
+	hashFunctions []*utils.Murmur3
+	seeds         []uint32
+}
+
+// NewPartitionedBloomFilter creates a new partitioned Bloom filter with specified capacity and error rate
+// capacity: maximum number of elements expected to be stored
+// errorRate: desired false positive probability
+func NewPartitionedBloomFilter(capacity uint, errorRate float64) (*PartitionedBloomFilter, error) {
+	if capacity < 1 || errorRate <= 0.0 || errorRate >= 1.0 {
+		return nil, errors.New("invalid capacity or error rate")
+	}
+
+	numberOfBits := uint(math.Ceil(-1.0 * float64(capacity) * math.Log(errorRate) / LN2SQRD))
+	numberOfHashes := uint(math.Ceil(-1.0 * math.Log(errorRate) / LN2))
+
+	return NewPartitionedBloomFilterWithParams(numberOfBits, numberOfHashes)
+}
+
+// NewPartitionedBloomFilterWithParams creates a new partitioned Bloom filter with specified bit array size and number of hash functions
+// m: size of bit array, rounded up to a multiple of k
+// k: number of hash functions and slices
+func NewPartitionedBloomFilterWithParams(m, k uint) (*PartitionedBloomFilter, error) {
+	if m <= 0 || k <= 0 || m < k {
+		return nil, errors.New("invalid m or k")
+	}
+
+	sliceSize := (m + k - 1) / k
+	hashFunctions := make([]*utils.Murmur3, k)
+	seeds := make([]uint32, k)
+	for i := range k {
+		seeds[i] = uint32(i + 1)
+		hashFunctions[i] = utils.NewMurmur3WithSeed(seeds[i])
+	}
+
+	return &PartitionedBloomFilter{
+		bitArray:      make([]bool, sliceSize*k),
+		bitCount:      sliceSize * k,
+		sliceSize:     sliceSize,
+		hashFuncCount: k,
+		hashFunctions: hashFunctions,
+		seeds:         seeds,
+	}, nil
+}
+
+// Add inserts an item into the partitioned Bloom filter
+// Returns error if insertion fails
+func (bf *PartitionedBloomFilter) Add(item []byte) error {
+	if err := validateInput(item); err != nil {
+		return err
+	}
+
+	for i, hashFunc := range bf.hashFunctions {
+		hash := hashFunc.Hash(item)
+		position := uint(i)*bf.sliceSize + uint(hash%uint32(bf.sliceSize))
+
+		bf.bitArray[position] = true
+	}
+
+	return nil
+}
+
+// Contains checks if an item might be in the partitioned Bloom filter
+// Returns true if item might be present, false if definitely not present
+func (bf *PartitionedBloomFilter) Contains(item []byte) (bool, error) {
+	if err := validateInput(item); err != nil {
+		return false, err
+	}
+
+	for i, hashFunc := range bf.hashFunctions {
+		hash := hashFunc.Hash(item)
+		position := uint(i)*bf.sliceSize + uint(hash%uint32(bf.sliceSize))
+
+		if !bf.bitArray[position] {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// sliceFill returns the number of set bits in each slice
+func (bf *PartitionedBloomFilter) sliceFill() []uint {
+	fill := make([]uint, bf.hashFuncCount)
+	for i, bit := range bf.bitArray {
+		if bit {
+			fill[uint(i)/bf.sliceSize]++
+		}
+	}
+
+	return fill
+}
+
+// Cardinality returns the estimated number of unique items in the partitioned Bloom filter
+// Every item sets one bit per slice, so each slice gives its own estimate and the
+// estimates are averaged
+func (bf *PartitionedBloomFilter) Cardinality() uint {
+	fill := bf.sliceFill()
+
+	s := float64(bf.sliceSize)
+	n := 0.0
+	for _, X := range fill {
+		if X == bf.sliceSize {
+			return bf.bitCount / bf.hashFuncCount
+		}
+		if s == 1 {
+			continue
+		}
+		n += math.Log(1-float64(X)/s) / math.Log(1-1/s)
+	}
+
+	return uint(math.Round(n / float64(bf.hashFuncCount)))
+}
+
+// FalsePositiveRate returns the current false positive rate of the partitioned Bloom filter
+func (bf *PartitionedBloomFilter) FalsePositiveRate() float32 {
+	probability := 1.0
+	for _, X := range bf.sliceFill() {
+		probability *= float64(X) / float64(bf.sliceSize)
+	}
+
+	return float32(probability)
+}
+
+// Size returns the number of bits in the filter
+func (bf *PartitionedBloomFilter) Size() uint {
+	return bf.bitCount
+}
+
+func (bf *PartitionedBloomFilter) Merge(other *PartitionedBloomFilter) (*PartitionedBloomFilter, error) {
+	if err := checkPartitionedCompatibility(bf, other); err != nil {
+		return nil, fmt.Errorf("cannot merge: %v", err)
+	}
+
+	result := bf.emptyCopy()
+	for i := range bf.bitArray {
+		result.bitArray[i] = bf.bitArray[i] || other.bitArray[i]
+	}
+
+	return result, nil
+}
+
+func (bf *PartitionedBloomFilter) Intersect(other *PartitionedBloomFilter) (*PartitionedBloomFilter, error) {
+	if err := checkPartitionedCompatibility(bf, other); err != nil {
+		return nil, fmt.Errorf("cannot intersect: %v", err)
+	}
+
+	result := bf.emptyCopy()
+	for i := range bf.bitArray {
+		result.bitArray[i] = bf.bitArray[i] && other.bitArray[i]
+	}
+
+	return result, nil
+}
+
+// emptyCopy returns an empty filter with the same parameters and seeds
+func (bf *PartitionedBloomFilter) emptyCopy() *PartitionedBloomFilter {
+	result := &PartitionedBloomFilter{
+		bitArray:      make([]bool, bf.bitCount),
+		bitCount:      bf.bitCount,
+		sliceSize:     bf.sliceSize,
+		hashFuncCount: bf.hashFuncCount,
+		hashFunctions: make([]*utils.Murmur3, bf.hashFuncCount),
+		seeds:         make([]uint32, bf.hashFuncCount),
+	}
+
+	copy(result.seeds, bf.seeds)
+	for i, seed := range result.seeds {
+		result.hashFunctions[i] = utils.NewMurmur3WithSeed(seed)
+	}
+
+	return result
+}
+
+func checkPartitionedCompatibility(bf1, bf2 *PartitionedBloomFilter) error {
+	if bf1.bitCount != bf2.bitCount {
+		return errors.New("bloom filters have different bit array sizes")
+	}
+	if bf1.hashFuncCount != bf2.hashFuncCount {
+		return errors.New("bloom filters have different numbers of hash functions")
+	}
+
+	for i, seed1 := range bf1.seeds {
+		if seed1 != bf2.seeds[i] {
+			return errors.New("bloom filters use different hash function seeds")
+		}
+	}
+
+	return nil
+}
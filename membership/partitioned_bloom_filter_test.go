@@ -0,0 +1,197 @@
+package membership
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestNewPartitionedBloomFilterWithParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		m           uint
+		k           uint
+		wantSize    uint
+		shouldError bool
+	}{
+		{
+			name:        "Valid parameters",
+			m:           1000,
+			k:           4,
+			wantSize:    1000,
+			shouldError: false,
+		},
+		{
+			name:        "Size rounded up to a multiple of k",
+			m:           1000,
+			k:           3,
+			wantSize:    1002,
+			shouldError: false,
+		},
+		{
+			name:        "Zero bit array size",
+			m:           0,
+			k:           3,
+			shouldError: true,
+		},
+		{
+			name:        "Zero hash functions",
+			m:           1000,
+			k:           0,
+			shouldError: true,
+		},
+		{
+			name:        "Fewer bits than slices",
+			m:           2,
+			k:           3,
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bf, err := NewPartitionedBloomFilterWithParams(tt.m, tt.k)
+			if tt.shouldError {
+				if err == nil {
+					t.Errorf("NewPartitionedBloomFilterWithParams() error = nil, expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewPartitionedBloomFilterWithParams() error = %v, expected no error", err)
+			}
+			if bf.Size() != tt.wantSize {
+				t.Errorf("Size() = %d, want %d", bf.Size(), tt.wantSize)
+			}
+		})
+	}
+
+	if _, err := NewPartitionedBloomFilter(0, 0.01); err == nil {
+		t.Error("NewPartitionedBloomFilter() with zero capacity should return error")
+	}
+}
+
+func TestPartitionedBloomFilter_Contains(t *testing.T) {
+	bf, err := NewPartitionedBloomFilter(1000, 0.01)
+	if err != nil {
+		t.Fatalf("Failed to create PartitionedBloomFilter: %v", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		if err := bf.Add([]byte(fmt.Sprintf("item%d", i))); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		if exists, _ := bf.Contains([]byte(fmt.Sprintf("item%d", i))); !exists {
+			t.Fatalf("Contains() false negative for item%d", i)
+		}
+	}
+
+	if err := bf.Add(nil); err == nil {
+		t.Error("Add() of nil item should return error")
+	}
+	if _, err := bf.Contains([]byte{}); err == nil {
+		t.Error("Contains() of empty item should return error")
+	}
+
+	// Each item sets exactly one bit per slice
+	for i, X := range bf.sliceFill() {
+		if X == 0 || X > 1000 {
+			t.Errorf("slice %d has %d bits set", i, X)
+		}
+	}
+}
+
+func TestPartitionedBloomFilter_FalsePositiveRate(t *testing.T) {
+	capacity := 20000
+	errorRate := 0.01
+	bf, err := NewPartitionedBloomFilter(uint(capacity), errorRate)
+	if err != nil {
+		t.Fatalf("Failed to create PartitionedBloomFilter: %v", err)
+	}
+
+	for i := 0; i < capacity; i++ {
+		bf.Add([]byte(fmt.Sprintf("item%d", i)))
+	}
+
+	falsePositives := 0
+	trials := 100000
+	for i := 0; i < trials; i++ {
+		if exists, _ := bf.Contains([]byte(fmt.Sprintf("other%d", i))); exists {
+			falsePositives++
+		}
+	}
+
+	rate := float64(falsePositives) / float64(trials)
+	if rate > 1.3*errorRate {
+		t.Errorf("False positive rate too high: got %.5f, want about %.5f", rate, errorRate)
+	}
+
+	predicted := float64(bf.FalsePositiveRate())
+	if math.Abs(rate-predicted) > 0.3*predicted {
+		t.Errorf("False positive rate %.5f does not match prediction %.5f", rate, predicted)
+	}
+}
+
+func TestPartitionedBloomFilter_Cardinality(t *testing.T) {
+	bf, err := NewPartitionedBloomFilter(10000, 0.01)
+	if err != nil {
+		t.Fatalf("Failed to create PartitionedBloomFilter: %v", err)
+	}
+
+	if bf.Cardinality() != 0 {
+		t.Errorf("Cardinality() of empty filter = %d, want 0", bf.Cardinality())
+	}
+
+	for i := 0; i < 5000; i++ {
+		bf.Add([]byte(fmt.Sprintf("item%d", i)))
+		// Duplicates do not change the estimate
+		bf.Add([]byte(fmt.Sprintf("item%d", i)))
+	}
+
+	estimate := float64(bf.Cardinality())
+	if math.Abs(estimate-5000) > 0.05*5000 {
+		t.Errorf("Cardinality() = %v, want about 5000", estimate)
+	}
+}
+
+func TestPartitionedBloomFilter_MergeIntersect(t *testing.T) {
+	bf1, _ := NewPartitionedBloomFilter(1000, 0.01)
+	bf2, _ := NewPartitionedBloomFilter(1000, 0.01)
+
+	bf1.Add([]byte("item1"))
+	bf1.Add([]byte("shared"))
+	bf2.Add([]byte("item2"))
+	bf2.Add([]byte("shared"))
+
+	merged, err := bf1.Merge(bf2)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	for _, item := range []string{"item1", "item2", "shared"} {
+		if exists, _ := merged.Contains([]byte(item)); !exists {
+			t.Errorf("Merged filter should contain %s", item)
+		}
+	}
+
+	intersected, err := bf1.Intersect(bf2)
+	if err != nil {
+		t.Fatalf("Intersect failed: %v", err)
+	}
+	if exists, _ := intersected.Contains([]byte("shared")); !exists {
+		t.Error("Intersected filter should contain shared")
+	}
+	if exists, _ := intersected.Contains([]byte("item1")); exists {
+		t.Error("Intersected filter should not contain item1")
+	}
+
+	other, _ := NewPartitionedBloomFilter(2000, 0.01)
+	if _, err := bf1.Merge(other); err == nil {
+		t.Error("Merge of incompatible filters should return error")
+	}
+	if _, err := bf1.Intersect(other); err == nil {
+		t.Error("Intersect of incompatible filters should return error")
+	}
+}
@@ -0,0 +1,539 @@
+package membership
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// QUOTIENT_FILTER_MAX_LOAD is the load factor used to size a quotient filter from a capacity
+	QUOTIENT_FILTER_MAX_LOAD = 0.75
+
+	qfOccupied     uint64 = 1 << 0
+	qfContinuation uint64 = 1 << 1
+	qfShifted      uint64 = 1 << 2
+	qfMetadataBits        = 3
+	qfMetadataMask uint64 = qfOccupied | qfContinuation | qfShifted
+)
+
+// QuotientFilter implements a quotient filter (Bender et al.), a compact hash table
+// of fingerprint remainders that supports deletion, merging and resizing without
+// access to the original items
+//
+// Each fingerprint of q+r bits is split into a quotient (the canonical slot) and a
+// remainder stored in the slot. Remainders with the same quotient form a sorted run,
+// and runs are shifted right when their canonical slots are taken. Three metadata
+// bits per slot keep track of this layout
+type QuotientFilter struct {
+	slots         []uint64
+	quotientBits  uint
+	remainderBits uint
+	indexMask     uint64
+	remainderMask uint64
+	count         uint
+}
+
+// NewQuotientFilter creates a new quotient filter with specified capacity and error rate
+// capacity: maximum number of elements expected to be stored
+// errorRate: desired false positive probability
+func NewQuotientFilter(capacity uint, errorRate float64) (*QuotientFilter, error) {
+	if capacity < 1 || errorRate <= 0.0 || errorRate >= 1.0 {
+		return nil, errors.New("invalid capacity or error rate")
+	}
+
+	quotientBits := uint(math.Ceil(math.Log2(float64(capacity) / QUOTIENT_FILTER_MAX_LOAD)))
+	if quotientBits < 1 {
+		quotientBits = 1
+	}
+	remainderBits := uint(math.Ceil(-1.0 * math.Log2(errorRate)))
+
+	return NewQuotientFilterWithParams(quotientBits, remainderBits)
+}
+
+// NewQuotientFilterWithParams creates a new quotient filter with 2^q slots holding r-bit remainders
+// q: number of quotient bits
+// r: number of remainder bits
+func NewQuotientFilterWithParams(q, r uint) (*QuotientFilter, error) {
+	if q < 1 || q > 32 {
+		return nil, errors.New("invalid q, must be between 1 and 32")
+	}
+
+	if r < 1 || q+r > 64 || r > 64-qfMetadataBits {
+		return nil, errors.New("invalid r, must be at least 1 with q+r at most 64")
+	}
+
+	return &QuotientFilter{
+		slots:         make([]uint64, 1<<q),
+		quotientBits:  q,
+		remainderBits: r,
+		indexMask:     (1 << q) - 1,
+		remainderMask: (1 << r) - 1,
+	}, nil
+}
+
+func qfIsEmpty(slot uint64) bool {
+	return slot&qfMetadataMask == 0
+}
+
+func qfIsOccupied(slot uint64) bool {
+	return slot&qfOccupied != 0
+}
+
+func qfIsContinuation(slot uint64) bool {
+	return slot&qfContinuation != 0
+}
+
+func qfIsShifted(slot uint64) bool {
+	return slot&qfShifted != 0
+}
+
+func qfIsClusterStart(slot uint64) bool {
+	return qfIsOccupied(slot) && !qfIsContinuation(slot) && !qfIsShifted(slot)
+}
+
+func qfIsRunStart(slot uint64) bool {
+	return !qfIsContinuation(slot) && (qfIsOccupied(slot) || qfIsShifted(slot))
+}
+
+func qfRemainder(slot uint64) uint64 {
+	return slot >> qfMetadataBits
+}
+
+func (qf *QuotientFilter) incr(index uint64) uint64 {
+	return (index + 1) & qf.indexMask
+}
+
+func (qf *QuotientFilter) decr(index uint64) uint64 {
+	return (index - 1) & qf.indexMask
+}
+
+// fingerprint returns the quotient and remainder of the item's hash
+func (qf *QuotientFilter) fingerprint(item []byte) (uint64, uint64) {
+	hash := hash64(item)
+	return qf.split(hash)
+}
+
+// split divides the low q+r bits of a hash into quotient and remainder
+func (qf *QuotientFilter) split(hash uint64) (uint64, uint64) {
+	return (hash >> qf.remainderBits) & qf.indexMask, hash & qf.remainderMask
+}
+
+// findRunIndex returns the slot where the run of the given quotient starts
+func (qf *QuotientFilter) findRunIndex(quotient uint64) uint64 {
+	// Walk back to the start of the cluster
+	b := quotient
+	for qfIsShifted(qf.slots[b]) {
+		b = qf.decr(b)
+	}
+
+	// Walk forward run by run until the run of quotient is reached
+	s := b
+	for b != quotient {
+		for {
+			s = qf.incr(s)
+			if !qfIsContinuation(qf.slots[s]) {
+				break
+			}
+		}
+		for {
+			b = qf.incr(b)
+			if qfIsOccupied(qf.slots[b]) {
+				break
+			}
+		}
+	}
+
+	return s
+}
+
+// insertAt places entry at slot s and shifts the following entries right until an
+// empty slot is found. The occupied bits stay with their slots
+func (qf *QuotientFilter) insertAt(s uint64, entry uint64) {
+	current := entry
+	for {
+		previous := qf.slots[s]
+		empty := qfIsEmpty(previous)
+		if !empty {
+			previous |= qfShifted
+			if qfIsOccupied(previous) {
+				current |= qfOccupied
+				previous &^= qfOccupied
+			}
+		}
+		qf.slots[s] = current
+		current = previous
+		s = qf.incr(s)
+		if empty {
+			return
+		}
+	}
+}
+
+// Insert adds an item to the filter
+// Returns error if the item is invalid or the filter is full
+func (qf *QuotientFilter) Insert(item []byte) error {
+	if err := validateInput(item); err != nil {
+		return err
+	}
+
+	quotient, remainder := qf.fingerprint(item)
+	return qf.insertFingerprint(quotient, remainder)
+}
+
+// insertFingerprint adds a quotient and remainder pair. A remainder already in the
+// run is stored again, so the run is a multiset and deleting one of two items with
+// the same fingerprint leaves the other
+func (qf *QuotientFilter) insertFingerprint(quotient, remainder uint64) error {
+	if qf.count >= uint(len(qf.slots)) {
+		return ErrFilterFull
+	}
+
+	offset := uint64(0)
+	if qfIsOccupied(qf.slots[quotient]) {
+		// Move to the sorted position of the remainder within the run
+		s := qf.findRunIndex(quotient)
+		for {
+			if qfRemainder(qf.slots[s]) >= remainder {
+				break
+			}
+			s = qf.incr(s)
+			offset++
+			if !qfIsContinuation(qf.slots[s]) {
+				break
+			}
+		}
+	}
+
+	qf.insertSlot(quotient, offset, remainder)
+	return nil
+}
+
+// insertSlot stores a remainder at the given offset of the run of quotient,
+// creating the run if it does not exist. The caller must ensure a slot is free
+func (qf *QuotientFilter) insertSlot(quotient, offset, remainder uint64) {
+	canonical := qf.slots[quotient]
+	entry := remainder << qfMetadataBits
+	qf.count++
+
+	if qfIsEmpty(canonical) {
+		qf.slots[quotient] = entry | qfOccupied
+		return
+	}
+
+	runExists := qfIsOccupied(canonical)
+	if !runExists {
+		qf.slots[quotient] = canonical | qfOccupied
+	}
+
+	start := qf.findRunIndex(quotient)
+	s := (start + offset) & qf.indexMask
+	if runExists {
+		if offset == 0 {
+			// The old run head becomes a continuation of the new one
+			qf.slots[start] |= qfContinuation
+		} else {
+			entry |= qfContinuation
+		}
+	}
+
+	if s != quotient {
+		entry |= qfShifted
+	}
+
+	qf.insertAt(s, entry)
+}
+
+// Contains checks if an item might be in the filter
+// Returns true if item might be present, false if definitely not present
+func (qf *QuotientFilter) Contains(item []byte) (bool, error) {
+	if err := validateInput(item); err != nil {
+		return false, err
+	}
+
+	quotient, remainder := qf.fingerprint(item)
+	return qf.containsFingerprint(quotient, remainder), nil
+}
+
+func (qf *QuotientFilter) containsFingerprint(quotient, remainder uint64) bool {
+	if !qfIsOccupied(qf.slots[quotient]) {
+		return false
+	}
+
+	s := qf.findRunIndex(quotient)
+	for {
+		existing := qfRemainder(qf.slots[s])
+		if existing == remainder {
+			return true
+		}
+		if existing > remainder {
+			return false
+		}
+		s = qf.incr(s)
+		if !qfIsContinuation(qf.slots[s]) {
+			return false
+		}
+	}
+}
+
+// Delete removes one copy of an item from the filter
+// Returns true if a matching fingerprint was found and removed
+func (qf *QuotientFilter) Delete(item []byte) (bool, error) {
+	if err := validateInput(item); err != nil {
+		return false, err
+	}
+
+	quotient, remainder := qf.fingerprint(item)
+	return qf.deleteFingerprint(quotient, remainder), nil
+}
+
+func (qf *QuotientFilter) deleteFingerprint(quotient, remainder uint64) bool {
+	if !qfIsOccupied(qf.slots[quotient]) {
+		return false
+	}
+
+	s := qf.findRunIndex(quotient)
+	offset := uint64(0)
+	for {
+		existing := qfRemainder(qf.slots[s])
+		if existing == remainder {
+			break
+		}
+		if existing > remainder {
+			return false
+		}
+		s = qf.incr(s)
+		offset++
+		if !qfIsContinuation(qf.slots[s]) {
+			return false
+		}
+	}
+
+	qf.deleteSlot(quotient, offset)
+	return true
+}
+
+// deleteSlot removes the entry at the given offset of the run of quotient
+func (qf *QuotientFilter) deleteSlot(quotient, offset uint64) {
+	s := (qf.findRunIndex(quotient) + offset) & qf.indexMask
+	kill := qf.slots[s]
+	replaceRunStart := qfIsRunStart(kill)
+
+	// Deleting the only entry of a run clears the occupied bit of its quotient
+	if replaceRunStart && !qfIsContinuation(qf.slots[qf.incr(s)]) {
+		qf.slots[quotient] &^= qfOccupied
+	}
+
+	qf.removeAt(s, quotient)
+
+	if replaceRunStart {
+		next := qf.slots[s]
+		updated := next
+		if qfIsContinuation(next) {
+			// The next entry of the run becomes its head
+			updated &^= qfContinuation
+		}
+		if s == quotient && qfIsRunStart(updated) {
+			updated &^= qfShifted
+		}
+		qf.slots[s] = updated
+	}
+
+	qf.count--
+}
+
+// readRun returns the remainders stored in the run of quotient
+func (qf *QuotientFilter) readRun(quotient uint64) []uint64 {
+	if !qfIsOccupied(qf.slots[quotient]) {
+		return nil
+	}
+
+	run := make([]uint64, 0, 4)
+	s := qf.findRunIndex(quotient)
+	for {
+		run = append(run, qfRemainder(qf.slots[s]))
+		s = qf.incr(s)
+		if !qfIsContinuation(qf.slots[s]) {
+			return run
+		}
+	}
+}
+
+// writeRun replaces the run of quotient with the given remainders, growing or
+// shrinking it in place. The caller must ensure enough slots are free
+func (qf *QuotientFilter) writeRun(quotient uint64, values []uint64) {
+	length := uint64(len(qf.readRun(quotient)))
+	target := uint64(len(values))
+
+	if length > 0 {
+		s := qf.findRunIndex(quotient)
+		for i := uint64(0); i < min(length, target); i++ {
+			qf.slots[s] = qf.slots[s]&qfMetadataMask | values[i]<<qfMetadataBits
+			s = qf.incr(s)
+		}
+	}
+
+	for ; length < target; length++ {
+		qf.insertSlot(quotient, length, values[length])
+	}
+
+	for ; length > target; length-- {
+		qf.deleteSlot(quotient, length-1)
+	}
+}
+
+// removeAt deletes the entry at slot s and shifts the rest of the cluster left,
+// clearing the shifted bit of runs that move back into their canonical slot
+func (qf *QuotientFilter) removeAt(s uint64, quotient uint64) {
+	current := qf.slots[s]
+	sp := qf.incr(s)
+	orig := s
+
+	for {
+		next := qf.slots[sp]
+		currentOccupied := qfIsOccupied(current)
+
+		if qfIsEmpty(next) || qfIsClusterStart(next) || sp == orig {
+			qf.slots[s] = 0
+			if currentOccupied {
+				qf.slots[s] = qfOccupied
+			}
+			return
+		}
+
+		updated := next
+		if qfIsRunStart(next) {
+			for {
+				quotient = qf.incr(quotient)
+				if qfIsOccupied(qf.slots[quotient]) {
+					break
+				}
+			}
+			if currentOccupied && quotient == s {
+				updated &^= qfShifted
+			}
+		}
+
+		if currentOccupied {
+			updated |= qfOccupied
+		} else {
+			updated &^= qfOccupied
+		}
+		qf.slots[s] = updated
+
+		s = sp
+		sp = qf.incr(sp)
+		current = next
+	}
+}
+
+// fingerprints returns every stored fingerprint as quotient<<r | remainder
+func (qf *QuotientFilter) fingerprints() []uint64 {
+	result := make([]uint64, 0, qf.count)
+	if qf.count == 0 {
+		return result
+	}
+
+	// Start the scan at an empty slot or at the beginning of a cluster
+	start := uint64(0)
+	for !qfIsEmpty(qf.slots[start]) && !qfIsClusterStart(qf.slots[start]) {
+		start = qf.incr(start)
+	}
+
+	quotient := start
+	for n := uint64(0); n < uint64(len(qf.slots)); n++ {
+		i := (start + n) & qf.indexMask
+		slot := qf.slots[i]
+		if qfIsEmpty(slot) {
+			continue
+		}
+
+		if qfIsClusterStart(slot) {
+			quotient = i
+		} else if !qfIsContinuation(slot) {
+			for {
+				quotient = qf.incr(quotient)
+				if qfIsOccupied(qf.slots[quotient]) {
+					break
+				}
+			}
+		}
+
+		result = append(result, quotient<<qf.remainderBits|qfRemainder(slot))
+	}
+
+	return result
+}
+
+// Resize returns a new filter with twice as many slots, holding the same fingerprints
+// One remainder bit is moved into the quotient, so the false positive rate per
+// stored item grows accordingly
+func (qf *QuotientFilter) Resize() (*QuotientFilter, error) {
+	if qf.remainderBits < 2 {
+		return nil, errors.New("cannot resize: not enough remainder bits")
+	}
+
+	result, err := NewQuotientFilterWithParams(qf.quotientBits+1, qf.remainderBits-1)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resize: %v", err)
+	}
+
+	for _, fp := range qf.fingerprints() {
+		if err := result.insertFingerprint(result.split(fp)); err != nil {
+			return nil, fmt.Errorf("cannot resize: %v", err)
+		}
+	}
+
+	return result, nil
+}
+
+// Merge returns a new filter holding the fingerprints of both filters
+// Both filters must use the same fingerprint length q+r. The result keeps the
+// larger quotient and grows until it fits the combined items
+func (qf *QuotientFilter) Merge(other *QuotientFilter) (*QuotientFilter, error) {
+	if qf.quotientBits+qf.remainderBits != other.quotientBits+other.remainderBits {
+		return nil, errors.New("cannot merge: quotient filters have different fingerprint sizes")
+	}
+
+	quotientBits := max(qf.quotientBits, other.quotientBits)
+	fingerprintBits := qf.quotientBits + qf.remainderBits
+	for float64(qf.count+other.count) > QUOTIENT_FILTER_MAX_LOAD*float64(uint64(1)<<quotientBits) &&
+		fingerprintBits-quotientBits > 1 {
+		quotientBits++
+	}
+
+	result, err := NewQuotientFilterWithParams(quotientBits, fingerprintBits-quotientBits)
+	if err != nil {
+		return nil, fmt.Errorf("cannot merge: %v", err)
+	}
+
+	for _, source := range []*QuotientFilter{qf, other} {
+		for _, fp := range source.fingerprints() {
+			if err := result.insertFingerprint(result.split(fp)); err != nil {
+				return nil, fmt.Errorf("cannot merge: %v", err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Count returns the number of fingerprints stored in the filter
+func (qf *QuotientFilter) Count() uint {
+	return qf.count
+}
+
+// LoadFactor returns the fraction of occupied slots
+func (qf *QuotientFilter) LoadFactor() float64 {
+	return float64(qf.count) / float64(len(qf.slots))
+}
+
+// Size returns the number of slots in the filter
+func (qf *QuotientFilter) Size() uint {
+	return uint(len(qf.slots))
+}
+
+// FalsePositiveRate returns the current false positive rate of the filter
+func (qf *QuotientFilter) FalsePositiveRate() float64 {
+	return 1 - math.Exp(-1.0*qf.LoadFactor()/math.Exp2(float64(qf.remainderBits)))
+}
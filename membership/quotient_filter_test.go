@@ -0,0 +1,334 @@
+package membership
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestNewQuotientFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		capacity  uint
+		errorRate float64
+		wantErr   bool
+	}{
+		{
+			name:      "valid parameters",
+			capacity:  1000,
+			errorRate: 0.01,
+			wantErr:   false,
+		},
+		{
+			name:      "zero capacity",
+			capacity:  0,
+			errorRate: 0.01,
+			wantErr:   true,
+		},
+		{
+			name:      "error rate too high",
+			capacity:  1000,
+			errorRate: 1.5,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewQuotientFilter(tt.capacity, tt.errorRate)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewQuotientFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewQuotientFilterWithParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       uint
+		r       uint
+		wantErr bool
+	}{
+		{name: "valid parameters", q: 10, r: 8, wantErr: false},
+		{name: "zero quotient bits", q: 0, r: 8, wantErr: true},
+		{name: "zero remainder bits", q: 10, r: 0, wantErr: true},
+		{name: "fingerprint too long", q: 32, r: 40, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewQuotientFilterWithParams(tt.q, tt.r)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewQuotientFilterWithParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuotientFilter_BasicOperations(t *testing.T) {
+	qf, err := NewQuotientFilter(1000, 0.01)
+	if err != nil {
+		t.Fatalf("Failed to create QuotientFilter: %v", err)
+	}
+
+	item1 := []byte("test1")
+	item2 := []byte("test2")
+
+	if err := qf.Insert(item1); err != nil {
+		t.Errorf("Insert() error = %v", err)
+	}
+
+	if exists, _ := qf.Contains(item1); !exists {
+		t.Error("Contains() failed to find inserted item")
+	}
+
+	if exists, _ := qf.Contains(item2); exists {
+		t.Error("Contains() found non-existent item")
+	}
+
+	if deleted, _ := qf.Delete(item1); !deleted {
+		t.Error("Delete() failed to remove existing item")
+	}
+
+	if deleted, _ := qf.Delete(item2); deleted {
+		t.Error("Delete() removed non-existent item")
+	}
+
+	if exists, _ := qf.Contains(item1); exists {
+		t.Error("Contains() found deleted item")
+	}
+
+	if err := qf.Insert(nil); err == nil {
+		t.Error("Insert() of nil item should return error")
+	}
+}
+
+func TestQuotientFilter_RandomOperations(t *testing.T) {
+	// A small table forces long clusters and wrap-around
+	qf, err := NewQuotientFilterWithParams(6, 4)
+	if err != nil {
+		t.Fatalf("Failed to create QuotientFilter: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	reference := make(map[uint64]int)
+	total := uint(0)
+	for step := 0; step < 20000; step++ {
+		fp := uint64(rng.Intn(1 << 10))
+		quotient, remainder := qf.split(fp)
+
+		if rng.Intn(2) == 0 && qf.Count() < qf.Size() {
+			if err := qf.insertFingerprint(quotient, remainder); err != nil {
+				t.Fatalf("insertFingerprint() error = %v", err)
+			}
+			reference[fp]++
+			total++
+		} else {
+			deleted := qf.deleteFingerprint(quotient, remainder)
+			if deleted != (reference[fp] > 0) {
+				t.Fatalf("step %d: deleteFingerprint(%d) = %v, want %v", step, fp, deleted, reference[fp] > 0)
+			}
+			if deleted {
+				total--
+				if reference[fp]--; reference[fp] == 0 {
+					delete(reference, fp)
+				}
+			}
+		}
+
+		if qf.Count() != total {
+			t.Fatalf("step %d: Count() = %d, want %d", step, qf.Count(), total)
+		}
+
+		for want := range reference {
+			if !qf.containsFingerprint(qf.split(want)) {
+				t.Fatalf("step %d: false negative for fingerprint %d", step, want)
+			}
+		}
+	}
+
+	got := qf.fingerprints()
+	slices.Sort(got)
+	want := make([]uint64, 0, total)
+	for fp, copies := range reference {
+		for range copies {
+			want = append(want, fp)
+		}
+	}
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("fingerprints() = %v, want %v", got, want)
+	}
+}
+
+func TestQuotientFilter_SharedFingerprint(t *testing.T) {
+	qf, err := NewQuotientFilterWithParams(4, 2)
+	if err != nil {
+		t.Fatalf("Failed to create QuotientFilter: %v", err)
+	}
+
+	// Find two keys with the same quotient and remainder
+	first := []byte("k0")
+	quotient, remainder := qf.fingerprint(first)
+	var second []byte
+	for i := 1; second == nil; i++ {
+		key := []byte(fmt.Sprintf("k%d", i))
+		if q, r := qf.fingerprint(key); q == quotient && r == remainder {
+			second = key
+		}
+	}
+
+	qf.Insert(first)
+	qf.Insert(second)
+	if qf.Count() != 2 {
+		t.Errorf("Count() = %d, want 2", qf.Count())
+	}
+
+	if deleted, _ := qf.Delete(first); !deleted {
+		t.Fatal("Delete() failed to remove existing item")
+	}
+	if exists, _ := qf.Contains(second); !exists {
+		t.Error("Contains() false negative for an item sharing the fingerprint of a deleted item")
+	}
+	if qf.Count() != 1 {
+		t.Errorf("Count() = %d, want 1", qf.Count())
+	}
+}
+
+func TestQuotientFilter_FullCapacity(t *testing.T) {
+	qf, err := NewQuotientFilterWithParams(4, 8)
+	if err != nil {
+		t.Fatalf("Failed to create QuotientFilter: %v", err)
+	}
+
+	inserted := 0
+	for i := 0; i < 1000 && qf.Count() < qf.Size(); i++ {
+		if err := qf.Insert([]byte(fmt.Sprintf("item%d", i))); err != nil {
+			t.Fatalf("Insert() error before filter is full: %v", err)
+		}
+		inserted++
+	}
+
+	if err := qf.Insert([]byte("one more")); err == nil {
+		t.Error("Insert() into full filter should return error")
+	}
+
+	for i := 0; i < inserted; i++ {
+		if exists, _ := qf.Contains([]byte(fmt.Sprintf("item%d", i))); !exists {
+			t.Errorf("Contains() false negative for item%d", i)
+		}
+	}
+}
+
+func TestQuotientFilter_Resize(t *testing.T) {
+	qf, err := NewQuotientFilterWithParams(8, 12)
+	if err != nil {
+		t.Fatalf("Failed to create QuotientFilter: %v", err)
+	}
+
+	for i := 0; i < 200; i++ {
+		if err := qf.Insert([]byte(fmt.Sprintf("item%d", i))); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	resized, err := qf.Resize()
+	if err != nil {
+		t.Fatalf("Resize() error = %v", err)
+	}
+
+	if resized.Size() != 2*qf.Size() {
+		t.Errorf("Resize() size = %d, want %d", resized.Size(), 2*qf.Size())
+	}
+
+	if resized.Count() != qf.Count() {
+		t.Errorf("Resize() count = %d, want %d", resized.Count(), qf.Count())
+	}
+
+	for i := 0; i < 200; i++ {
+		if exists, _ := resized.Contains([]byte(fmt.Sprintf("item%d", i))); !exists {
+			t.Errorf("Contains() false negative for item%d after resize", i)
+		}
+	}
+
+	// Items inserted after resizing are found alongside the old ones
+	for i := 200; i < 400; i++ {
+		if err := resized.Insert([]byte(fmt.Sprintf("item%d", i))); err != nil {
+			t.Fatalf("Insert() after resize error = %v", err)
+		}
+	}
+	for i := 0; i < 400; i++ {
+		if exists, _ := resized.Contains([]byte(fmt.Sprintf("item%d", i))); !exists {
+			t.Errorf("Contains() false negative for item%d", i)
+		}
+	}
+
+	small, _ := NewQuotientFilterWithParams(8, 1)
+	if _, err := small.Resize(); err == nil {
+		t.Error("Resize() without spare remainder bits should return error")
+	}
+}
+
+func TestQuotientFilter_Merge(t *testing.T) {
+	qf1, _ := NewQuotientFilterWithParams(8, 12)
+	qf2, _ := NewQuotientFilterWithParams(8, 12)
+
+	for i := 0; i < 150; i++ {
+		if err := qf1.Insert([]byte(fmt.Sprintf("left%d", i))); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+		if err := qf2.Insert([]byte(fmt.Sprintf("right%d", i))); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	merged, err := qf1.Merge(qf2)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	if merged.Size() <= qf1.Size() {
+		t.Errorf("Merge() should grow the filter for the combined items, got size %d", merged.Size())
+	}
+
+	for i := 0; i < 150; i++ {
+		for _, item := range []string{fmt.Sprintf("left%d", i), fmt.Sprintf("right%d", i)} {
+			if exists, _ := merged.Contains([]byte(item)); !exists {
+				t.Errorf("Contains() false negative for %s after merge", item)
+			}
+		}
+	}
+
+	incompatible, _ := NewQuotientFilterWithParams(8, 10)
+	if _, err := qf1.Merge(incompatible); err == nil {
+		t.Error("Merge of incompatible filters should return error")
+	}
+}
+
+func TestQuotientFilter_FalsePositiveRate(t *testing.T) {
+	qf, err := NewQuotientFilter(10000, 0.01)
+	if err != nil {
+		t.Fatalf("Failed to create QuotientFilter: %v", err)
+	}
+
+	for i := 0; i < 10000; i++ {
+		if err := qf.Insert([]byte(fmt.Sprintf("item%d", i))); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	falsePositives := 0
+	trials := 100000
+	for i := 0; i < trials; i++ {
+		if exists, _ := qf.Contains([]byte(fmt.Sprintf("other%d", i))); exists {
+			falsePositives++
+		}
+	}
+
+	rate := float64(falsePositives) / float64(trials)
+	if rate > 0.01 {
+		t.Errorf("False positive rate too high: got %.4f, want <= 0.01", rate)
+	}
+}
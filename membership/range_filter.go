@@ -0,0 +1,170 @@
+package membership
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// RangeFilter implements a hierarchical prefix Bloom filter for range queries over
+// 64-bit keys, in the style of Rosetta (Luo et al.). Level l holds the prefixes
+// key >> l, so each entry stands for a dyadic interval of 2^l keys. A range is split
+// into dyadic intervals; an interval whose prefix is present is resolved by checking
+// its two halves one level down until a key is confirmed at level 0. This keeps the
+// false positive rate close to that of a point query for short ranges
+//
+// Byte-string keys are reduced to their first 8 bytes, read big-endian so their order
+// is kept, and shorter keys are padded with zero bytes. Longer keys with the same
+// 8-byte prefix are the same key to the filter: each is reported present once any
+// of them is added
+type RangeFilter struct {
+	levels   []*BloomFilter
+	maxLevel uint
+}
+
+// NewRangeFilter creates a new range filter
+// capacity: maximum number of keys expected to be stored
+// errorRate: desired false positive probability of each level
+// maxRangeLength: longest range that can be queried, at least 1
+func NewRangeFilter(capacity uint, errorRate float64, maxRangeLength uint64) (*RangeFilter, error) {
+	if maxRangeLength < 1 {
+		return nil, errors.New("invalid maximum range length")
+	}
+
+	// Enough levels for a dyadic interval of the maximum range length
+	maxLevel := uint(bits.Len64(maxRangeLength - 1))
+
+	levels := make([]*BloomFilter, maxLevel+1)
+	for i := range levels {
+		bf, err := NewBloomFilter(capacity, errorRate)
+		if err != nil {
+			return nil, err
+		}
+		levels[i] = bf
+	}
+
+	return &RangeFilter{levels: levels, maxLevel: maxLevel}, nil
+}
+
+// Add inserts a key into every level of the range filter
+// Returns error if insertion fails
+func (rf *RangeFilter) Add(key uint64) error {
+	for level, bf := range rf.levels {
+		if err := bf.Add(encodePrefix(key >> level)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddBytes inserts a byte-string key, indexed by its first 8 bytes
+// Keys sharing an 8-byte prefix cannot be told apart by range queries
+func (rf *RangeFilter) AddBytes(key []byte) error {
+	if err := validateInput(key); err != nil {
+		return err
+	}
+
+	return rf.Add(bytesPrefix(key))
+}
+
+// Contains checks if a key might be in the range filter
+// Returns true if key might be present, false if definitely not present
+func (rf *RangeFilter) Contains(key uint64) bool {
+	exists, _ := rf.levels[0].Contains(encodePrefix(key))
+	return exists
+}
+
+// MayContainRange checks if any key in [lo, hi) might be in the range filter
+// Returns true if a key might be present, false if the range is definitely empty
+// Returns error if the range is empty or longer than the maximum range length
+func (rf *RangeFilter) MayContainRange(lo, hi uint64) (bool, error) {
+	if lo >= hi {
+		return false, errors.New("invalid range, lo must be less than hi")
+	}
+
+	return rf.mayContain(lo, hi-1)
+}
+
+// MayContainRangeBytes checks if any byte-string key in [lo, hi) might be in the range filter
+// Keys are compared by their first 8 bytes, so the bounds are widened to whole prefixes.
+// The prefix of hi is left out when no key with that prefix sorts before hi, which
+// holds when hi has at most 8 bytes and no trailing zero byte. Otherwise the upper
+// end is rounded up, and keys from hi onwards that share its prefix are reported
+// Returns error if the range is empty or the widened range is longer than the
+// maximum range length
+func (rf *RangeFilter) MayContainRangeBytes(lo, hi []byte) (bool, error) {
+	if err := validateInput(lo); err != nil {
+		return false, err
+	}
+	if err := validateInput(hi); err != nil {
+		return false, err
+	}
+	if bytes.Compare(lo, hi) >= 0 {
+		return false, errors.New("invalid range, lo must be less than hi")
+	}
+
+	// Every key in the range has a prefix between those of the bounds. When hi is
+	// the shortest key with its prefix, lo has a smaller prefix, as lo < hi
+	first, last := bytesPrefix(lo), bytesPrefix(hi)
+	if len(hi) <= 8 && (hi[len(hi)-1] != 0 || len(hi) == 1) {
+		last--
+	}
+
+	return rf.mayContain(first, last)
+}
+
+// mayContain checks the inclusive range [lo, hi] by its dyadic decomposition
+func (rf *RangeFilter) mayContain(lo, hi uint64) (bool, error) {
+	if hi-lo > (uint64(1)<<rf.maxLevel)-1 {
+		return false, errors.New("range longer than maximum range length")
+	}
+
+	for {
+		// The largest aligned interval starting at lo that fits in the range
+		level := min(uint(bits.TrailingZeros64(lo)), rf.maxLevel)
+		for level > 0 && hi-lo < (uint64(1)<<level)-1 {
+			level--
+		}
+
+		if rf.probe(lo>>level, level) {
+			return true, nil
+		}
+
+		end := lo + (uint64(1) << level) - 1
+		if end >= hi {
+			return false, nil
+		}
+		lo = end + 1
+	}
+}
+
+// probe checks a dyadic interval and resolves a positive answer by its halves
+func (rf *RangeFilter) probe(prefix uint64, level uint) bool {
+	if exists, _ := rf.levels[level].Contains(encodePrefix(prefix)); !exists {
+		return false
+	}
+
+	if level == 0 {
+		return true
+	}
+
+	return rf.probe(prefix<<1, level-1) || rf.probe(prefix<<1|1, level-1)
+}
+
+// MaxRangeLength returns the longest range that can be queried
+func (rf *RangeFilter) MaxRangeLength() uint64 {
+	return uint64(1) << rf.maxLevel
+}
+
+func encodePrefix(prefix uint64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, prefix)
+}
+
+// bytesPrefix maps the first 8 bytes of a key to an integer, preserving byte order
+func bytesPrefix(key []byte) uint64 {
+	var buf [8]byte
+	copy(buf[:], key)
+	return binary.BigEndian.Uint64(buf[:])
+}
@@ -0,0 +1,192 @@
+package membership
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestNewRangeFilter(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxRangeLength uint64
+		wantMax        uint64
+		shouldError    bool
+	}{
+		{name: "Point queries only", maxRangeLength: 1, wantMax: 1},
+		{name: "Power of two", maxRangeLength: 1024, wantMax: 1024},
+		{name: "Rounded up to a power of two", maxRangeLength: 1000, wantMax: 1024},
+		{name: "Zero length", maxRangeLength: 0, shouldError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rf, err := NewRangeFilter(1000, 0.01, tt.maxRangeLength)
+			if tt.shouldError {
+				if err == nil {
+					t.Error("NewRangeFilter() error = nil, expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewRangeFilter() error = %v", err)
+			}
+			if rf.MaxRangeLength() != tt.wantMax {
+				t.Errorf("MaxRangeLength() = %d, want %d", rf.MaxRangeLength(), tt.wantMax)
+			}
+		})
+	}
+
+	if _, err := NewRangeFilter(0, 0.01, 16); err == nil {
+		t.Error("NewRangeFilter() with zero capacity should return error")
+	}
+}
+
+func TestRangeFilter_MayContainRange(t *testing.T) {
+	rf, _ := NewRangeFilter(100, 0.01, 1<<16)
+	for _, key := range []uint64{0, 1000, 1 << 40, ^uint64(0)} {
+		rf.Add(key)
+	}
+
+	tests := []struct {
+		lo, hi uint64
+		want   bool
+	}{
+		{lo: 0, hi: 1, want: true},
+		{lo: 990, hi: 1001, want: true},
+		{lo: 1000, hi: 1001, want: true},
+		{lo: 1 << 40, hi: 1<<40 + 50000, want: true},
+		{lo: 1<<40 - 60000, hi: 1<<40 + 1, want: true},
+		{lo: ^uint64(0) - 100, hi: ^uint64(0), want: false},
+		{lo: 1001, hi: 1 << 16, want: false},
+	}
+
+	for _, tt := range tests {
+		got, err := rf.MayContainRange(tt.lo, tt.hi)
+		if err != nil {
+			t.Errorf("MayContainRange(%d, %d) error = %v", tt.lo, tt.hi, err)
+			continue
+		}
+		if tt.want && !got {
+			t.Errorf("MayContainRange(%d, %d) = false, want true", tt.lo, tt.hi)
+		}
+	}
+
+	if !rf.Contains(^uint64(0)) {
+		t.Error("Contains() false negative for max key")
+	}
+
+	if _, err := rf.MayContainRange(5, 5); err == nil {
+		t.Error("MayContainRange() of empty range should return error")
+	}
+	if _, err := rf.MayContainRange(0, 1<<16+1); err == nil {
+		t.Error("MayContainRange() of too long range should return error")
+	}
+}
+
+func TestRangeFilter_FalsePositiveRate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	keys := make([]uint64, 10000)
+	rf, _ := NewRangeFilter(uint(len(keys)), 0.01, 1<<12)
+	for i := range keys {
+		keys[i] = rng.Uint64()
+		rf.Add(keys[i])
+	}
+	slices.Sort(keys)
+
+	// Ranges around stored keys are always found
+	for _, key := range keys[:1000] {
+		if exists, _ := rf.MayContainRange(key-key%64, key-key%64+64); !exists {
+			t.Fatalf("MayContainRange() false negative around %d", key)
+		}
+	}
+
+	// With doubt resolution the rate grows slowly with the range length
+	limits := map[uint64]float64{1: 0.015, 16: 0.03, 256: 0.05, 4096: 0.1}
+	for _, length := range []uint64{1, 16, 256, 4096} {
+		t.Run(fmt.Sprintf("length %d", length), func(t *testing.T) {
+			falsePositives, trials := 0, 0
+			for trials < 5000 {
+				lo := rng.Uint64() >> 1
+				// Skip ranges that really contain a key
+				i, _ := slices.BinarySearch(keys, lo)
+				if i < len(keys) && keys[i] < lo+length {
+					continue
+				}
+				trials++
+				if exists, _ := rf.MayContainRange(lo, lo+length); exists {
+					falsePositives++
+				}
+			}
+
+			rate := float64(falsePositives) / float64(trials)
+			t.Logf("range length %d: false positive rate %.4f", length, rate)
+			if rate > limits[length] {
+				t.Errorf("False positive rate too high: got %.4f, want <= %.4f", rate, limits[length])
+			}
+		})
+	}
+}
+
+func TestRangeFilter_Bytes(t *testing.T) {
+	rf, _ := NewRangeFilter(100, 0.01, 1<<20)
+	for _, key := range []string{"row00100", "row00200", "row00300-suffix"} {
+		if err := rf.AddBytes([]byte(key)); err != nil {
+			t.Fatalf("AddBytes() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		lo, hi string
+		want   bool
+	}{
+		{lo: "row00100", hi: "row00101", want: true},
+		{lo: "row00195", hi: "row00205", want: true},
+		{lo: "row001", hi: "row002", want: true},
+		// Shares the 8-byte prefix of row00300-suffix
+		{lo: "row00300-a", hi: "row00300-b", want: true},
+		{lo: "row00150", hi: "row00160", want: false},
+		{lo: "row00201", hi: "row00299", want: false},
+		// The upper bound is excluded when it is the shortest key with its prefix
+		{lo: "row00150", hi: "row00200", want: false},
+		{lo: "row002", hi: "row003", want: true},
+		// A longer upper bound rounds up to its whole prefix
+		{lo: "row00250", hi: "row00300-a", want: true},
+	}
+
+	for _, tt := range tests {
+		got, err := rf.MayContainRangeBytes([]byte(tt.lo), []byte(tt.hi))
+		if err != nil {
+			t.Errorf("MayContainRangeBytes(%q, %q) error = %v", tt.lo, tt.hi, err)
+			continue
+		}
+		// The hashes are fixed, so the empty ranges are known to be free of false positives
+		if got != tt.want {
+			t.Errorf("MayContainRangeBytes(%q, %q) = %v, want %v", tt.lo, tt.hi, got, tt.want)
+		}
+	}
+
+	if _, err := rf.MayContainRangeBytes([]byte("a"), []byte("z")); err == nil {
+		t.Error("MayContainRangeBytes() of too long range should return error")
+	}
+	// "row0040" sorts before "row0040\x00" and shares its padded prefix, so that
+	// prefix must not be left out
+	short, _ := NewRangeFilter(100, 0.01, 1<<20)
+	short.AddBytes([]byte("row0040"))
+	if got, _ := short.MayContainRangeBytes([]byte("row00399"), []byte("row0040\x00")); !got {
+		t.Error("MayContainRangeBytes() missed a shorter key below an upper bound ending in a zero byte")
+	}
+
+	for _, bounds := range [][2]string{{"b", "a"}, {"row00100", "row00100"}, {"row00300-b", "row00300-a"}} {
+		if _, err := rf.MayContainRangeBytes([]byte(bounds[0]), []byte(bounds[1])); err == nil {
+			t.Errorf("MayContainRangeBytes(%q, %q) of empty range should return error", bounds[0], bounds[1])
+		}
+	}
+	if _, err := rf.MayContainRangeBytes(nil, []byte("a")); err == nil {
+		t.Error("MayContainRangeBytes() with nil bound should return error")
+	}
+	if err := rf.AddBytes(nil); err == nil {
+		t.Error("AddBytes() of nil key should return error")
+	}
+}
@@ -0,0 +1,189 @@
+package membership
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+const (
+	// RIBBON_FILTER_OVERHEAD is the fraction of extra slots allocated beyond one per key
+	RIBBON_FILTER_OVERHEAD = 0.1
+
+	// ribbonWidth is the number of coefficient bits per key (one machine word)
+	ribbonWidth = 64
+
+	ribbonFilterHeaderSize = 17
+
+	ribbonCoeffSalt = 0x6a09e667f3bcc909
+	ribbonFreeSalt  = 0xbb67ae8584caa73b
+)
+
+// RibbonFilter implements a static homogeneous Ribbon filter (Dillinger & Walzer)
+//
+// Every key is turned into a linear equation over GF(2): a 64-bit coefficient row
+// starting at a hashed slot must multiply the stored solution to zero in each of
+// the r result bits. The system is solved by banded Gaussian elimination and only
+// the solution is stored. A homogeneous system is always solvable, so construction
+// never fails, and free variables are filled pseudo-randomly so that non-members
+// match with probability close to 2^-r. The filter uses about r bits per key plus
+// RIBBON_FILTER_OVERHEAD, for any r between 1 and 64
+type RibbonFilter struct {
+	seed       uint64
+	numSlots   uint64
+	bitsPerKey uint
+	// solution is stored column-wise in blocks of 64 slots: word b*r+j holds bit j
+	// of the solution rows of slots b*64 to b*64+63
+	solution []uint64
+}
+
+// NewRibbonFilter builds a Ribbon filter from the given keys
+// keys: the static key set, duplicates are ignored
+// bitsPerKey: number of result bits r, giving a false positive rate of about 2^-r
+func NewRibbonFilter(keys [][]byte, bitsPerKey uint) (*RibbonFilter, error) {
+	if bitsPerKey < 1 || bitsPerKey > 64 {
+		return nil, errors.New("invalid bits per key, must be between 1 and 64")
+	}
+
+	hashes, err := hashKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	numSlots := uint64(math.Ceil(float64(len(hashes))*(1+RIBBON_FILTER_OVERHEAD))) + ribbonWidth
+	numSlots = (numSlots + ribbonWidth - 1) / ribbonWidth * ribbonWidth
+
+	rngState := uint64(len(hashes))
+	rf := &RibbonFilter{
+		seed:       splitMix64(&rngState),
+		numSlots:   numSlots,
+		bitsPerKey: bitsPerKey,
+	}
+	rf.build(hashes)
+
+	return rf, nil
+}
+
+// equation returns the start slot and coefficient row of a key
+func (rf *RibbonFilter) equation(key uint64) (uint64, uint64) {
+	hash := mixSplit(key, rf.seed)
+	start, _ := bits.Mul64(hash, rf.numSlots-ribbonWidth+1)
+	coeff := mixSplit(hash, ribbonCoeffSalt) | 1
+	return start, coeff
+}
+
+// build runs banded Gaussian elimination over all keys and back-substitutes the
+// solution. Equations that reduce to zero are implied by earlier ones
+func (rf *RibbonFilter) build(hashes []uint64) {
+	coeffRows := make([]uint64, rf.numSlots)
+
+	for _, key := range hashes {
+		start, coeff := rf.equation(key)
+		for coeff != 0 {
+			if coeffRows[start] == 0 {
+				coeffRows[start] = coeff
+				break
+			}
+
+			coeff ^= coeffRows[start]
+			shift := bits.TrailingZeros64(coeff)
+			start += uint64(shift)
+			coeff >>= uint(shift)
+		}
+	}
+
+	// Back-substitute from the last slot. state[j] holds bit j of the solution
+	// rows of the next 64 slots, so each row is one parity per column
+	r := uint64(rf.bitsPerKey)
+	rf.solution = make([]uint64, rf.numSlots/ribbonWidth*r)
+	state := make([]uint64, r)
+	for i := rf.numSlots; i > 0; i-- {
+		row := i - 1
+		block, offset := row/ribbonWidth, row%ribbonWidth
+		free := mixSplit(row, rf.seed^ribbonFreeSalt)
+		for j := uint64(0); j < r; j++ {
+			state[j] <<= 1
+			bit := (free >> j) & 1
+			if coeffRows[row] != 0 {
+				bit = uint64(bits.OnesCount64(coeffRows[row]&state[j])) & 1
+			}
+			state[j] |= bit
+			rf.solution[block*r+j] |= bit << offset
+		}
+	}
+}
+
+// Contains checks if an item might be in the filter
+// Returns true if item might be present, false if definitely not present
+func (rf *RibbonFilter) Contains(item []byte) (bool, error) {
+	if err := validateInput(item); err != nil {
+		return false, err
+	}
+
+	start, coeff := rf.equation(hash64(item))
+	r := uint64(rf.bitsPerKey)
+	block, offset := start/ribbonWidth, start%ribbonWidth
+
+	for j := uint64(0); j < r; j++ {
+		column := rf.solution[block*r+j] >> offset
+		if offset > 0 {
+			column |= rf.solution[(block+1)*r+j] << (ribbonWidth - offset)
+		}
+		if bits.OnesCount64(column&coeff)&1 != 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Size returns the number of slots in the filter
+func (rf *RibbonFilter) Size() uint {
+	return uint(rf.numSlots)
+}
+
+// SizeInBits returns the number of bits used by the solution
+func (rf *RibbonFilter) SizeInBits() uint {
+	return uint(rf.numSlots) * rf.bitsPerKey
+}
+
+// MarshalBinary encodes the filter as seed, slot count, bits per key and the
+// solution words in little-endian order
+func (rf *RibbonFilter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, ribbonFilterHeaderSize+8*len(rf.solution))
+	binary.LittleEndian.PutUint64(data[0:8], rf.seed)
+	binary.LittleEndian.PutUint64(data[8:16], rf.numSlots)
+	data[16] = byte(rf.bitsPerKey)
+	for i, word := range rf.solution {
+		binary.LittleEndian.PutUint64(data[ribbonFilterHeaderSize+8*i:], word)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes a filter produced by MarshalBinary
+func (rf *RibbonFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < ribbonFilterHeaderSize {
+		return errors.New("data too short for ribbon filter header")
+	}
+
+	numSlots := binary.LittleEndian.Uint64(data[8:16])
+	bitsPerKey := uint(data[16])
+	if bitsPerKey < 1 || bitsPerKey > 64 || numSlots < ribbonWidth || numSlots%ribbonWidth != 0 {
+		return errors.New("invalid ribbon filter header")
+	}
+
+	words := numSlots / ribbonWidth * uint64(bitsPerKey)
+	if uint64(len(data)-ribbonFilterHeaderSize) != 8*words {
+		return errors.New("data length does not match ribbon filter size")
+	}
+
+	rf.seed = binary.LittleEndian.Uint64(data[0:8])
+	rf.numSlots = numSlots
+	rf.bitsPerKey = bitsPerKey
+	rf.solution = make([]uint64, words)
+	for i := range rf.solution {
+		rf.solution[i] = binary.LittleEndian.Uint64(data[ribbonFilterHeaderSize+8*i:])
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package membership
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewRibbonFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       [][]byte
+		bitsPerKey uint
+		wantErr    bool
+	}{
+		{
+			name:       "valid parameters",
+			keys:       makeKeys("item", 1000),
+			bitsPerKey: 7,
+			wantErr:    false,
+		},
+		{
+			name:       "no keys",
+			keys:       [][]byte{},
+			bitsPerKey: 8,
+			wantErr:    false,
+		},
+		{
+			name:       "zero bits per key",
+			keys:       makeKeys("item", 10),
+			bitsPerKey: 0,
+			wantErr:    true,
+		},
+		{
+			name:       "too many bits per key",
+			keys:       makeKeys("item", 10),
+			bitsPerKey: 65,
+			wantErr:    true,
+		},
+		{
+			name:       "empty key",
+			keys:       [][]byte{[]byte("a"), {}},
+			bitsPerKey: 8,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRibbonFilter(tt.keys, tt.bitsPerKey)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewRibbonFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRibbonFilter_FalsePositiveRate(t *testing.T) {
+	// Bits per key that are not a whole byte are supported
+	for _, bitsPerKey := range []uint{1, 5, 7, 12} {
+		t.Run(fmt.Sprintf("%d bits", bitsPerKey), func(t *testing.T) {
+			keys := makeKeys("item", 100000)
+			rf, err := NewRibbonFilter(keys, bitsPerKey)
+			if err != nil {
+				t.Fatalf("Failed to create RibbonFilter: %v", err)
+			}
+
+			for _, key := range keys {
+				if exists, _ := rf.Contains(key); !exists {
+					t.Fatalf("Contains() false negative for %s", key)
+				}
+			}
+
+			falsePositives := 0
+			trials := 200000
+			for i := 0; i < trials; i++ {
+				if exists, _ := rf.Contains([]byte(fmt.Sprintf("other%d", i))); exists {
+					falsePositives++
+				}
+			}
+
+			rate := float64(falsePositives) / float64(trials)
+			expected := 1.0 / float64(uint(1)<<bitsPerKey)
+			if rate > 1.3*expected+0.0001 {
+				t.Errorf("False positive rate too high: got %.5f, want about %.5f", rate, expected)
+			}
+
+			bitsPerItem := float64(rf.SizeInBits()) / float64(len(keys))
+			if bitsPerItem > 1.12*float64(bitsPerKey) {
+				t.Errorf("Bits per key too high: got %.2f, want about %d", bitsPerItem, bitsPerKey)
+			}
+		})
+	}
+}
+
+func TestRibbonFilter_Serialization(t *testing.T) {
+	keys := makeKeys("item", 5000)
+	rf, err := NewRibbonFilter(keys, 9)
+	if err != nil {
+		t.Fatalf("Failed to create RibbonFilter: %v", err)
+	}
+
+	data, err := rf.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	decoded := &RibbonFilter{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	for _, key := range keys {
+		if exists, _ := decoded.Contains(key); !exists {
+			t.Fatalf("Contains() false negative for %s after decoding", key)
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		item := []byte(fmt.Sprintf("other%d", i))
+		want, _ := rf.Contains(item)
+		got, _ := decoded.Contains(item)
+		if got != want {
+			t.Fatalf("Contains(%s) after decoding = %v, want %v", item, got, want)
+		}
+	}
+
+	if err := decoded.UnmarshalBinary(data[:len(data)-8]); err == nil {
+		t.Error("UnmarshalBinary() of truncated data should return error")
+	}
+}
+
+func BenchmarkRibbonFilter_Contains(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	rf, err := NewRibbonFilter(keys, 8)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rf.Contains(keys[i%benchmarkKeys])
+	}
+
+	b.ReportMetric(float64(rf.SizeInBits())/benchmarkKeys, "bits/key")
+}
@@ -0,0 +1,118 @@
+package membership
+
+const (
+	// SEMI_SORTED_BUCKET_BITS is the size of a semi-sorted bucket of four 8-bit
+	// fingerprints: a 12-bit code for the sorted 4-bit prefixes and four 4-bit suffixes
+	SEMI_SORTED_BUCKET_BITS = 28
+
+	// semiSortedPrefixCodes is the number of multisets of four 4-bit prefixes, C(19, 4)
+	semiSortedPrefixCodes = 3876
+)
+
+// semiSortedPrefixes maps a prefix code to its four prefixes, packed in
+// non-increasing order from the most significant nibble
+var semiSortedPrefixes = buildSemiSortedPrefixes()
+
+func buildSemiSortedPrefixes() []uint16 {
+	prefixes := make([]uint16, semiSortedPrefixCodes)
+	for a := uint16(0); a < 16; a++ {
+		for b := uint16(0); b <= a; b++ {
+			for c := uint16(0); c <= b; c++ {
+				for d := uint16(0); d <= c; d++ {
+					prefixes[semiSortedCode(a, b, c, d)] = a<<12 | b<<8 | c<<4 | d
+				}
+			}
+		}
+	}
+
+	return prefixes
+}
+
+// semiSortedCode ranks the prefixes a >= b >= c >= d in the combinatorial number
+// system, after shifting them to the strictly decreasing a+3 > b+2 > c+1 > d
+func semiSortedCode(a, b, c, d uint16) uint16 {
+	return binomial(a+3, 4) + binomial(b+2, 3) + binomial(c+1, 2) + d
+}
+
+func binomial(n, k uint16) uint16 {
+	if n < k {
+		return 0
+	}
+
+	result := uint32(1)
+	for i := uint32(0); i < uint32(k); i++ {
+		result = result * (uint32(n) - i) / (i + 1)
+	}
+	return uint16(result)
+}
+
+// semiSortedTable stores buckets of four 8-bit fingerprints in 28 bits instead of 32.
+// The fingerprints of a bucket are kept in non-increasing order, so their 4-bit
+// prefixes form a sorted sequence with only 3876 possible values and fit in a
+// 12-bit code. Empty slots hold the fingerprint 0 and sort to the end of a bucket
+type semiSortedTable struct {
+	words []uint64
+}
+
+func newSemiSortedTable(numBuckets uint) *semiSortedTable {
+	return &semiSortedTable{
+		words: make([]uint64, (numBuckets*SEMI_SORTED_BUCKET_BITS+63)/64),
+	}
+}
+
+// read decodes the fingerprints of a bucket in non-increasing order
+func (t *semiSortedTable) read(index uint) [4]byte {
+	offset := index * SEMI_SORTED_BUCKET_BITS
+	word, shift := offset/64, offset%64
+	value := t.words[word] >> shift
+	if shift+SEMI_SORTED_BUCKET_BITS > 64 {
+		value |= t.words[word+1] << (64 - shift)
+	}
+
+	prefixes := semiSortedPrefixes[value&0xfff]
+	var fingerprints [4]byte
+	for i := range fingerprints {
+		prefix := byte(prefixes>>(12-4*i)) & 0xf
+		suffix := byte(value>>(12+4*i)) & 0xf
+		fingerprints[i] = prefix<<4 | suffix
+	}
+
+	return fingerprints
+}
+
+// write sorts the fingerprints of a bucket and stores them
+func (t *semiSortedTable) write(index uint, fingerprints [4]byte) {
+	// Sorting network for four values, largest first
+	for _, pair := range [5][2]int{{0, 1}, {2, 3}, {0, 2}, {1, 3}, {1, 2}} {
+		if fingerprints[pair[0]] < fingerprints[pair[1]] {
+			fingerprints[pair[0]], fingerprints[pair[1]] = fingerprints[pair[1]], fingerprints[pair[0]]
+		}
+	}
+
+	value := uint64(semiSortedCode(
+		uint16(fingerprints[0]>>4),
+		uint16(fingerprints[1]>>4),
+		uint16(fingerprints[2]>>4),
+		uint16(fingerprints[3]>>4),
+	))
+	for i, fingerprint := range fingerprints {
+		value |= uint64(fingerprint&0xf) << (12 + 4*i)
+	}
+
+	offset := index * SEMI_SORTED_BUCKET_BITS
+	word, shift := offset/64, offset%64
+	mask := uint64(1)<<SEMI_SORTED_BUCKET_BITS - 1
+	t.words[word] = t.words[word]&^(mask<<shift) | value<<shift
+	if shift+SEMI_SORTED_BUCKET_BITS > 64 {
+		t.words[word+1] = t.words[word+1]&^(mask>>(64-shift)) | value>>(64-shift)
+	}
+}
+
+// semiSortedSize returns the number of fingerprints stored in a decoded bucket
+func semiSortedSize(fingerprints [4]byte) uint {
+	size := uint(0)
+	for size < 4 && fingerprints[size] != 0 {
+		size++
+	}
+	return size
+}
@@ -0,0 +1,70 @@
+package membership
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSemiSortedPrefixes(t *testing.T) {
+	seen := make(map[uint16]bool, semiSortedPrefixCodes)
+	for code, prefixes := range semiSortedPrefixes {
+		a, b, c, d := prefixes>>12, prefixes>>8&0xf, prefixes>>4&0xf, prefixes&0xf
+		if a < b || b < c || c < d {
+			t.Fatalf("code %d decodes to unsorted prefixes %04x", code, prefixes)
+		}
+		if seen[prefixes] {
+			t.Fatalf("prefixes %04x decoded from more than one code", prefixes)
+		}
+		seen[prefixes] = true
+		if got := semiSortedCode(a, b, c, d); got != uint16(code) {
+			t.Fatalf("semiSortedCode(%04x) = %d, want %d", prefixes, got, code)
+		}
+	}
+}
+
+func TestSemiSortedTable_ReadWrite(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	numBuckets := uint(1000)
+	table := newSemiSortedTable(numBuckets)
+
+	want := make([][4]byte, numBuckets)
+	for i := range want {
+		// Random sizes, with empty slots as zero fingerprints
+		for j := rng.Intn(5); j > 0; j-- {
+			want[i][j-1] = byte(rng.Intn(255) + 1)
+		}
+		table.write(uint(i), want[i])
+	}
+
+	for i, fingerprints := range want {
+		got := table.read(uint(i))
+		counts := make(map[byte]int)
+		for _, fingerprint := range fingerprints {
+			counts[fingerprint]++
+		}
+		for j, fingerprint := range got {
+			if j > 0 && got[j-1] < fingerprint {
+				t.Fatalf("read(%d) = %v, not in non-increasing order", i, got)
+			}
+			counts[fingerprint]--
+		}
+		for _, count := range counts {
+			if count != 0 {
+				t.Fatalf("read(%d) = %v, want fingerprints of %v", i, got, fingerprints)
+			}
+		}
+		if semiSortedSize(got) != uint(4-countZeros(fingerprints)) {
+			t.Fatalf("semiSortedSize(%v) = %d", got, semiSortedSize(got))
+		}
+	}
+}
+
+func countZeros(fingerprints [4]byte) int {
+	zeros := 0
+	for _, fingerprint := range fingerprints {
+		if fingerprint == 0 {
+			zeros++
+		}
+	}
+	return zeros
+}
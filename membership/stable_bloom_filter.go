@@ -0,0 +1,185 @@
+package membership
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/mrtkp9993/probdsgo/utils"
+)
+
+// StableBloomFilter implements a Stable Bloom filter (Deng & Rafiei) for duplicate
+// detection on unbounded streams. Each cell is a small counter of d bits. Before an
+// item is added, p cells are decremented, and the item then sets its k cells to the
+// maximum counter value. Old items are gradually evicted, so the fraction of zero
+// cells converges to a stable point and the false positive rate stays bounded.
+// False negatives are possible for items that were added long ago
+type StableBloomFilter struct {
+	cells         []uint8
+	cellCount     uint
+	cellBits      uint
+	max           uint8
+	hashFuncCount uint
+	decrements    uint
+	hashFunctions []*utils.Murmur3
+	rng           *rand.Rand
+}
+
+// NewStableBloomFilter creates a new Stable Bloom filter whose false positive rate
+// converges to errorRate
+// m: number of cells
+// d: bits per cell, between 1 and 8
+// errorRate: desired false positive probability at the stable point
+// opts: WithSeed or WithRandSource make the decremented cells reproducible
+func NewStableBloomFilter(m, d uint, errorRate float64, opts ...Option) (*StableBloomFilter, error) {
+	if m < 2 || d < 1 || d > 8 || errorRate <= 0.0 || errorRate >= 1.0 {
+		return nil, errors.New("invalid m, d or error rate")
+	}
+
+	k := uint(math.Max(1, math.Min(math.Ceil(-1.0*math.Log2(errorRate)), float64(m-1))))
+	p, err := StableBloomDecrements(m, d, k, errorRate)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStableBloomFilterWithParams(m, d, k, p, opts...)
+}
+
+// NewStableBloomFilterWithParams creates a new Stable Bloom filter with explicit parameters
+// m: number of cells
+// d: bits per cell, between 1 and 8
+// k: number of hash functions, less than m
+// p: number of cells decremented per insertion
+// opts: WithSeed or WithRandSource make the decremented cells reproducible
+func NewStableBloomFilterWithParams(m, d, k, p uint, opts ...Option) (*StableBloomFilter, error) {
+	if m < 2 || d < 1 || d > 8 || k <= 0 || k >= m || p <= 0 || p > m {
+		return nil, errors.New("invalid m, d, k or p")
+	}
+
+	hashFunctions := make([]*utils.Murmur3, k)
+	for i := range k {
+		hashFunctions[i] = utils.NewMurmur3WithSeed(uint32(i + 1))
+	}
+
+	return &StableBloomFilter{
+		cells:         make([]uint8, m),
+		cellCount:     m,
+		cellBits:      d,
+		max:           uint8(1<<d - 1),
+		hashFuncCount: k,
+		decrements:    p,
+		hashFunctions: hashFunctions,
+		rng:           newOptions(opts).rand(),
+	}, nil
+}
+
+// StableBloomDecrements returns the number of cells that must be decremented per
+// insertion for the false positive rate to converge to errorRate
+func StableBloomDecrements(m, d, k uint, errorRate float64) (uint, error) {
+	if m < 2 || d < 1 || d > 8 || k <= 0 || k >= m || errorRate <= 0.0 || errorRate >= 1.0 {
+		return 0, errors.New("invalid m, d, k or error rate")
+	}
+
+	// Invert the stable point formula of StableBloomFalsePositiveRate for p
+	zeros := 1 - math.Pow(errorRate, 1/float64(k))
+	maxValue := float64(uint(1)<<d - 1)
+	denominator := (math.Pow(1/zeros, 1/maxValue) - 1) * (1/float64(k) - 1/float64(m))
+	p := uint(math.Max(1, math.Round(1/denominator)))
+
+	return min(p, m), nil
+}
+
+// StableBloomFalsePositiveRate returns the false positive rate of a Stable Bloom
+// filter at its stable point, where the expected fraction of zero cells is
+// (1 / (1 + 1/(p(1/k - 1/m))))^(2^d - 1)
+func StableBloomFalsePositiveRate(m, d, k, p uint) float64 {
+	maxValue := float64(uint(1)<<d - 1)
+	zeros := math.Pow(1/(1+1/(float64(p)*(1/float64(k)-1/float64(m)))), maxValue)
+
+	return math.Pow(1-zeros, float64(k))
+}
+
+// Add inserts an item into the Stable Bloom filter
+// Returns error if insertion fails
+func (sbf *StableBloomFilter) Add(item []byte) error {
+	if err := validateInput(item); err != nil {
+		return err
+	}
+
+	sbf.decrement()
+	for _, hashFunc := range sbf.hashFunctions {
+		position := hashFunc.Hash(item) % uint32(sbf.cellCount)
+		sbf.cells[position] = sbf.max
+	}
+
+	return nil
+}
+
+// Contains checks if an item was seen recently
+// Returns true if item might be present, false if it was not seen or has been evicted
+func (sbf *StableBloomFilter) Contains(item []byte) (bool, error) {
+	if err := validateInput(item); err != nil {
+		return false, err
+	}
+
+	for _, hashFunc := range sbf.hashFunctions {
+		position := hashFunc.Hash(item) % uint32(sbf.cellCount)
+		if sbf.cells[position] == 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// TestAndAdd checks if an item was seen recently and then adds it
+// Returns the result of Contains before the insertion
+func (sbf *StableBloomFilter) TestAndAdd(item []byte) (bool, error) {
+	exists, err := sbf.Contains(item)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, sbf.Add(item)
+}
+
+// decrement lowers p consecutive cells, starting at a random cell and wrapping around
+func (sbf *StableBloomFilter) decrement() {
+	position := uint(sbf.rng.Intn(int(sbf.cellCount)))
+	for range sbf.decrements {
+		if sbf.cells[position] > 0 {
+			sbf.cells[position]--
+		}
+		position++
+		if position == sbf.cellCount {
+			position = 0
+		}
+	}
+}
+
+// FalsePositiveRate returns the false positive rate the filter converges to
+func (sbf *StableBloomFilter) FalsePositiveRate() float64 {
+	return StableBloomFalsePositiveRate(sbf.cellCount, sbf.cellBits, sbf.hashFuncCount, sbf.decrements)
+}
+
+// CurrentFalsePositiveRate returns the false positive rate given the current fraction of nonzero cells
+func (sbf *StableBloomFilter) CurrentFalsePositiveRate() float64 {
+	nonzero := 0
+	for _, cell := range sbf.cells {
+		if cell > 0 {
+			nonzero++
+		}
+	}
+
+	return math.Pow(float64(nonzero)/float64(sbf.cellCount), float64(sbf.hashFuncCount))
+}
+
+// Size returns the number of cells in the filter
+func (sbf *StableBloomFilter) Size() uint {
+	return sbf.cellCount
+}
+
+// Decrements returns the number of cells decremented per insertion
+func (sbf *StableBloomFilter) Decrements() uint {
+	return sbf.decrements
+}
@@ -0,0 +1,160 @@
+package membership
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestNewStableBloomFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		m           uint
+		d           uint
+		errorRate   float64
+		shouldError bool
+	}{
+		{
+			name:        "Valid parameters",
+			m:           10000,
+			d:           3,
+			errorRate:   0.01,
+			shouldError: false,
+		},
+		{
+			name:        "Zero cells",
+			m:           0,
+			d:           3,
+			errorRate:   0.01,
+			shouldError: true,
+		},
+		{
+			name:        "Cells wider than a byte",
+			m:           10000,
+			d:           9,
+			errorRate:   0.01,
+			shouldError: true,
+		},
+		{
+			name:        "Error rate too high",
+			m:           10000,
+			d:           3,
+			errorRate:   1.0,
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sbf, err := NewStableBloomFilter(tt.m, tt.d, tt.errorRate)
+			if tt.shouldError {
+				if err == nil {
+					t.Errorf("NewStableBloomFilter() error = nil, expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewStableBloomFilter() error = %v, expected no error", err)
+			}
+			if rate := sbf.FalsePositiveRate(); math.Abs(rate-tt.errorRate) > 0.2*tt.errorRate {
+				t.Errorf("FalsePositiveRate() = %v, want about %v", rate, tt.errorRate)
+			}
+		})
+	}
+
+	if _, err := NewStableBloomFilterWithParams(100, 3, 100, 1); err == nil {
+		t.Error("NewStableBloomFilterWithParams() with k >= m should return error")
+	}
+}
+
+func TestStableBloomFilter_TestAndAdd(t *testing.T) {
+	sbf, err := NewStableBloomFilter(10000, 3, 0.01, WithSeed(1))
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+
+	if exists, _ := sbf.TestAndAdd([]byte("item1")); exists {
+		t.Error("TestAndAdd() reported an unseen item as present")
+	}
+	if exists, _ := sbf.TestAndAdd([]byte("item1")); !exists {
+		t.Error("TestAndAdd() did not find an item added just before")
+	}
+	if exists, _ := sbf.Contains([]byte("item1")); !exists {
+		t.Error("Contains() did not find an item added just before")
+	}
+
+	if _, err := sbf.TestAndAdd(nil); err == nil {
+		t.Error("TestAndAdd() of nil item should return error")
+	}
+	if _, err := sbf.Contains([]byte{}); err == nil {
+		t.Error("Contains() of empty item should return error")
+	}
+}
+
+func TestStableBloomFilter_Deterministic(t *testing.T) {
+	run := func() []uint8 {
+		sbf, _ := NewStableBloomFilter(1000, 2, 0.05, WithSeed(42))
+		for i := 0; i < 5000; i++ {
+			sbf.Add([]byte(fmt.Sprintf("item%d", i)))
+		}
+		return sbf.cells
+	}
+
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("cell %d differs between runs with the same seed: %d != %d", i, first[i], second[i])
+		}
+	}
+}
+
+func TestStableBloomFilter_StablePoint(t *testing.T) {
+	errorRate := 0.02
+	sbf, err := NewStableBloomFilter(20000, 3, errorRate, WithSeed(7))
+	if err != nil {
+		t.Fatalf("Failed to create StableBloomFilter: %v", err)
+	}
+
+	// Feed a stream many times the size of the filter; a plain Bloom filter would be full
+	for i := 0; i < 500000; i++ {
+		sbf.Add([]byte(fmt.Sprintf("item%d", i)))
+	}
+
+	if rate := sbf.CurrentFalsePositiveRate(); math.Abs(rate-errorRate) > 0.3*errorRate {
+		t.Errorf("CurrentFalsePositiveRate() = %v, want about %v", rate, errorRate)
+	}
+
+	falsePositives := 0
+	trials := 50000
+	for i := 0; i < trials; i++ {
+		if exists, _ := sbf.Contains([]byte(fmt.Sprintf("other%d", i))); exists {
+			falsePositives++
+		}
+	}
+
+	rate := float64(falsePositives) / float64(trials)
+	if math.Abs(rate-errorRate) > 0.3*errorRate {
+		t.Errorf("False positive rate = %v, want about %v", rate, errorRate)
+	}
+
+	// Recent items are still detected
+	for i := 500000 - 100; i < 500000; i++ {
+		if exists, _ := sbf.Contains([]byte(fmt.Sprintf("item%d", i))); !exists {
+			t.Errorf("Contains() false negative for recent item%d", i)
+		}
+	}
+}
+
+func TestStableBloomFalsePositiveRate(t *testing.T) {
+	for _, p := range []uint{1, 5, 10, 50} {
+		rate := StableBloomFalsePositiveRate(10000, 3, 4, p)
+		if rate <= 0 || rate >= 1 {
+			t.Errorf("StableBloomFalsePositiveRate(p=%d) = %v, want in (0, 1)", p, rate)
+		}
+	}
+
+	// More decrements evict more cells and lower the rate
+	if StableBloomFalsePositiveRate(10000, 3, 4, 10) >= StableBloomFalsePositiveRate(10000, 3, 4, 5) {
+		t.Error("StableBloomFalsePositiveRate() should decrease as p increases")
+	}
+}
@@ -0,0 +1,294 @@
+package membership
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/mrtkp9993/probdsgo/utils"
+)
+
+const (
+	// VACUUM_BUCKET_SIZE is the number of fingerprints per vacuum filter bucket
+	VACUUM_BUCKET_SIZE = 4
+
+	// VACUUM_LOAD_FACTOR is the maximum load factor of a vacuum filter
+	VACUUM_LOAD_FACTOR = 0.95
+
+	// vacuumMinRange is the smallest alternate range considered
+	vacuumMinRange = 8
+
+	// vacuumChunkLoad is the load up to which relocations can fill a single chunk
+	vacuumChunkLoad = 0.98
+
+	// vacuumMargin is the number of standard deviations kept free in the fullest chunk
+	vacuumMargin = 1.5
+)
+
+// VacuumFilter implements a vacuum filter (Wang et al.), a cuckoo filter variant
+// whose table size is any multiple of the alternate range length instead of a power
+// of two. The table is split into aligned chunks and the alternate bucket of a
+// fingerprint is found by XOR within its chunk, so it stays inside the table. The
+// fingerprints are split into four groups with alternate ranges L, L, L/2 and L/4:
+// the large range balances load between chunks and the small ones keep relocations
+// local. Before relocating at random, insertion looks for an entry of a full bucket
+// that can move to its alternate bucket directly
+type VacuumFilter struct {
+	// buckets holds VACUUM_BUCKET_SIZE fingerprints per bucket, 0 marks an empty slot
+	buckets             []byte
+	victim              victimSlot
+	numBuckets          uint
+	ranges              [4]uint
+	altOffsets          [256]uint
+	hashFunc            *utils.Murmur3
+	fingerprintHashFunc *utils.Murmur3
+	rng                 *rand.Rand
+	count               uint
+}
+
+// NewVacuumFilter creates a new vacuum filter with 8-bit fingerprints
+// capacity: maximum number of items expected to be stored
+// opts: WithSeed or WithRandSource make relocations reproducible
+func NewVacuumFilter(capacity uint, opts ...Option) (*VacuumFilter, error) {
+	if capacity < 1 {
+		return nil, errors.New("invalid capacity")
+	}
+
+	numBuckets, rangeLength := vacuumTableSize(capacity)
+
+	vf := &VacuumFilter{
+		buckets:             make([]byte, numBuckets*VACUUM_BUCKET_SIZE),
+		numBuckets:          numBuckets,
+		hashFunc:            utils.NewMurmur3WithSeed(0),
+		fingerprintHashFunc: utils.NewMurmur3WithSeed(1),
+		rng:                 newOptions(opts).rand(),
+	}
+
+	for r, shift := range [4]uint{0, 0, 1, 2} {
+		vf.ranges[r] = max(rangeLength>>shift, 1)
+	}
+	for fp := range vf.altOffsets {
+		hash := uint(vf.fingerprintHashFunc.Hash([]byte{byte(fp)}))
+		vf.altOffsets[fp] = hash & (vf.ranges[fp%4] - 1)
+	}
+
+	return vf, nil
+}
+
+// vacuumTableSize returns the smallest table, in buckets, that holds capacity items
+// together with its alternate range L, as in the range selection of Wang et al.
+// The table is a whole number of aligned chunks of L buckets, and an item never
+// leaves its chunk, so the fullest chunk must still leave room for relocations.
+// Its load is estimated as the maximum of Poisson loads, λ + sqrt(2λ ln k) for k
+// chunks of λ items each, plus vacuumMargin standard deviations. Each power of two
+// is tried as L with the fewest chunks that pass, so small tables get short ranges
+// and are never rounded up to a power of two
+func vacuumTableSize(capacity uint) (uint, uint) {
+	n := float64(capacity)
+	minBuckets := math.Ceil(n / (VACUUM_LOAD_FACTOR * VACUUM_BUCKET_SIZE))
+
+	var bestBuckets, bestRange uint
+	for rangeLength := uint(vacuumMinRange); ; rangeLength <<= 1 {
+		limit := vacuumChunkLoad * float64(rangeLength*VACUUM_BUCKET_SIZE)
+		chunks := max(math.Ceil(minBuckets/float64(rangeLength)), 1)
+		for {
+			items := n / chunks
+			if items+math.Sqrt(2*items*math.Log(chunks))+vacuumMargin*math.Sqrt(items) <= limit {
+				break
+			}
+			chunks++
+		}
+
+		if buckets := uint(chunks) * rangeLength; bestBuckets == 0 || buckets <= bestBuckets {
+			bestBuckets, bestRange = buckets, rangeLength
+		}
+		if chunks == 1 {
+			// Longer ranges only give larger single-chunk tables
+			return bestBuckets, bestRange
+		}
+	}
+}
+
+// generateFingerprint creates a non-zero 8-bit fingerprint for the given item
+func (vf *VacuumFilter) generateFingerprint(item []byte) byte {
+	fp := byte(vf.fingerprintHashFunc.Hash(item))
+	if fp == 0 {
+		fp = 1
+	}
+	return fp
+}
+
+// getIndices returns the two possible bucket indices for an item
+func (vf *VacuumFilter) getIndices(item []byte, fingerprint byte) (uint, uint) {
+	index1 := uint(reduce(vf.hashFunc.Hash(item), uint32(vf.numBuckets)))
+	return index1, vf.altIndex(index1, fingerprint)
+}
+
+// altIndex returns the other bucket a fingerprint may be stored in, within the
+// aligned chunk of its alternate range
+func (vf *VacuumFilter) altIndex(index uint, fingerprint byte) uint {
+	return index ^ vf.altOffsets[fingerprint]
+}
+
+// Insert adds an item to the filter
+func (vf *VacuumFilter) Insert(item []byte) error {
+	if err := validateInput(item); err != nil {
+		return err
+	}
+
+	if float64(vf.count) >= VACUUM_LOAD_FACTOR*float64(vf.Size()) || vf.victim.used {
+		return ErrFilterFull
+	}
+
+	fingerprint := vf.generateFingerprint(item)
+	i1, i2 := vf.getIndices(item, fingerprint)
+
+	return vf.insertFingerprint(fingerprint, i1, i2)
+}
+
+// insertFingerprint stores a fingerprint in one of its two buckets, relocating
+// existing fingerprints when both are full. As in CuckooFilter, the fingerprint
+// left over after MaxKicks relocations is kept in the victim slot
+func (vf *VacuumFilter) insertFingerprint(fingerprint byte, i1, i2 uint) error {
+	if vf.victim.used {
+		return ErrFilterFull
+	}
+
+	if vf.bucketAppend(i1, fingerprint) || vf.bucketAppend(i2, fingerprint) {
+		vf.count++
+		return nil
+	}
+
+	// Vacuuming: make room by moving one entry of a full bucket to its alternate bucket
+	for _, index := range [2]uint{i1, i2} {
+		for position := uint(0); position < VACUUM_BUCKET_SIZE; position++ {
+			slot := index*VACUUM_BUCKET_SIZE + position
+			if vf.bucketAppend(vf.altIndex(index, vf.buckets[slot]), vf.buckets[slot]) {
+				vf.buckets[slot] = fingerprint
+				vf.count++
+				return nil
+			}
+		}
+	}
+
+	currentFp := fingerprint
+	currentIndex := i1
+	if vf.rng.Intn(2) == 0 {
+		currentIndex = i2
+	}
+
+	for range MaxKicks {
+		slot := currentIndex*VACUUM_BUCKET_SIZE + uint(vf.rng.Intn(VACUUM_BUCKET_SIZE))
+		currentFp, vf.buckets[slot] = vf.buckets[slot], currentFp
+
+		currentIndex = vf.altIndex(currentIndex, currentFp)
+		if vf.bucketAppend(currentIndex, currentFp) {
+			vf.count++
+			return nil
+		}
+	}
+
+	vf.victim = victimSlot{fingerprint: currentFp, index: currentIndex, used: true}
+	vf.count++
+	return nil
+}
+
+// bucketAppend stores a fingerprint in a free slot of a bucket, if there is one
+func (vf *VacuumFilter) bucketAppend(index uint, fingerprint byte) bool {
+	bucket := vf.buckets[index*VACUUM_BUCKET_SIZE : (index+1)*VACUUM_BUCKET_SIZE]
+	for i := range bucket {
+		if bucket[i] == 0 {
+			bucket[i] = fingerprint
+			return true
+		}
+	}
+	return false
+}
+
+// bucketContains reports whether a bucket holds the fingerprint
+func (vf *VacuumFilter) bucketContains(index uint, fingerprint byte) bool {
+	bucket := vf.buckets[index*VACUUM_BUCKET_SIZE : (index+1)*VACUUM_BUCKET_SIZE]
+	return bucket[0] == fingerprint || bucket[1] == fingerprint ||
+		bucket[2] == fingerprint || bucket[3] == fingerprint
+}
+
+// victimMatches reports whether the victim is the given fingerprint stored for buckets i1 and i2
+func (vf *VacuumFilter) victimMatches(fingerprint byte, i1, i2 uint) bool {
+	return vf.victim.used && vf.victim.fingerprint == fingerprint && (vf.victim.index == i1 || vf.victim.index == i2)
+}
+
+// Lookup checks if an item might be in the filter
+// Returns false for an invalid item
+func (vf *VacuumFilter) Lookup(item []byte) bool {
+	if validateInput(item) != nil {
+		return false
+	}
+
+	fingerprint := vf.generateFingerprint(item)
+	i1, i2 := vf.getIndices(item, fingerprint)
+
+	return vf.bucketContains(i1, fingerprint) || vf.bucketContains(i2, fingerprint) || vf.victimMatches(fingerprint, i1, i2)
+}
+
+// Delete removes one copy of an item from the filter
+// Returns false for an invalid item
+func (vf *VacuumFilter) Delete(item []byte) bool {
+	if validateInput(item) != nil {
+		return false
+	}
+
+	fingerprint := vf.generateFingerprint(item)
+	i1, i2 := vf.getIndices(item, fingerprint)
+
+	if vf.victimMatches(fingerprint, i1, i2) {
+		vf.victim.used = false
+		vf.count--
+		return true
+	}
+
+	for _, index := range [2]uint{i1, i2} {
+		bucket := vf.buckets[index*VACUUM_BUCKET_SIZE : (index+1)*VACUUM_BUCKET_SIZE]
+		for i := range bucket {
+			if bucket[i] == fingerprint {
+				bucket[i] = 0
+				vf.count--
+				vf.reinsertVictim()
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// reinsertVictim tries to move the victim back into the buckets after a deletion
+func (vf *VacuumFilter) reinsertVictim() {
+	if !vf.victim.used {
+		return
+	}
+
+	victim := vf.victim
+	vf.victim.used = false
+	vf.insertFingerprint(victim.fingerprint, victim.index, vf.altIndex(victim.index, victim.fingerprint))
+	vf.count--
+}
+
+// Count returns the number of items in the filter
+func (vf *VacuumFilter) Count() uint {
+	return vf.count
+}
+
+// LoadFactor returns the fraction of occupied fingerprint slots
+func (vf *VacuumFilter) LoadFactor() float64 {
+	return float64(vf.count) / float64(vf.Size())
+}
+
+// Size returns the number of fingerprint slots
+func (vf *VacuumFilter) Size() uint {
+	return vf.numBuckets * VACUUM_BUCKET_SIZE
+}
+
+// SizeInBits returns the number of bits used to store fingerprints
+func (vf *VacuumFilter) SizeInBits() uint {
+	return vf.Size() * 8
+}
@@ -0,0 +1,281 @@
+package membership
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestNewVacuumFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		capacity    uint
+		wantBuckets uint
+		wantErr     bool
+	}{
+		{name: "small table is one short range", capacity: 100, wantBuckets: 32, wantErr: false},
+		{name: "medium table is a multiple of the range", capacity: 5000, wantBuckets: 1536, wantErr: false},
+		{name: "large table is a multiple of the range", capacity: 1000000, wantBuckets: 266240, wantErr: false},
+		{name: "invalid capacity", capacity: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vf, err := NewVacuumFilter(tt.capacity)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewVacuumFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && vf.numBuckets != tt.wantBuckets {
+				t.Errorf("numBuckets = %d, want %d", vf.numBuckets, tt.wantBuckets)
+			}
+			if err == nil && vf.numBuckets%vf.ranges[0] != 0 {
+				t.Errorf("numBuckets = %d, not a multiple of the range %d", vf.numBuckets, vf.ranges[0])
+			}
+		})
+	}
+}
+
+func TestVacuumFilter_BasicOperations(t *testing.T) {
+	vf, err := NewVacuumFilter(1000)
+	if err != nil {
+		t.Fatalf("Failed to create VacuumFilter: %v", err)
+	}
+
+	item1 := []byte("test1")
+	item2 := []byte("test2")
+
+	if err := vf.Insert(item1); err != nil {
+		t.Errorf("Insert() error = %v", err)
+	}
+	if !vf.Lookup(item1) {
+		t.Error("Lookup() failed to find inserted item")
+	}
+	if vf.Lookup(item2) {
+		t.Error("Lookup() found non-existent item")
+	}
+
+	if !vf.Delete(item1) {
+		t.Error("Delete() failed to remove existing item")
+	}
+	if vf.Delete(item2) {
+		t.Error("Delete() removed non-existent item")
+	}
+	if vf.Lookup(item1) {
+		t.Error("Lookup() found deleted item")
+	}
+	if vf.Count() != 0 {
+		t.Errorf("Count() = %d, want 0", vf.Count())
+	}
+}
+
+func TestVacuumFilter_SizeBelowPowerOfTwo(t *testing.T) {
+	vf, err := NewVacuumFilter(5000)
+	if err != nil {
+		t.Fatalf("Failed to create VacuumFilter: %v", err)
+	}
+
+	nextPowerOf2 := getNextPowerOf2(vf.numBuckets) * VACUUM_BUCKET_SIZE
+	if vf.Size() >= nextPowerOf2 {
+		t.Errorf("Size() = %d, want below the next power of two %d", vf.Size(), nextPowerOf2)
+	}
+}
+
+func TestVacuumFilter_InvalidInput(t *testing.T) {
+	vf, _ := NewVacuumFilter(100)
+
+	for _, item := range [][]byte{nil, {}} {
+		if err := vf.Insert(item); err == nil {
+			t.Errorf("Insert(%v) succeeded, want error", item)
+		}
+		if vf.Lookup(item) {
+			t.Errorf("Lookup(%v) = true, want false", item)
+		}
+		if vf.Delete(item) {
+			t.Errorf("Delete(%v) = true, want false", item)
+		}
+	}
+}
+
+func TestVacuumFilter_FullCapacity(t *testing.T) {
+	// Capacities just above a power-of-two table, where CuckooFilter doubles its size
+	for _, capacity := range []uint{1000, 5000, 12345, 20000, 250000} {
+		t.Run(fmt.Sprintf("capacity %d", capacity), func(t *testing.T) {
+			vf, err := NewVacuumFilter(capacity, WithSeed(1))
+			if err != nil {
+				t.Fatalf("Failed to create VacuumFilter: %v", err)
+			}
+
+			n := uint(0)
+			for ; n < capacity; n++ {
+				if err := vf.Insert([]byte(fmt.Sprintf("item%d", n))); err != nil {
+					t.Fatalf("Insert() error = %v after %d of %d items", err, n, capacity)
+				}
+			}
+			if vf.Count() != capacity {
+				t.Errorf("Count() = %d, want %d", vf.Count(), capacity)
+			}
+
+			for i := uint(0); i < n; i++ {
+				if !vf.Lookup([]byte(fmt.Sprintf("item%d", i))) {
+					t.Fatalf("Lookup() false negative for item%d", i)
+				}
+			}
+
+			falsePositives := 0
+			for i := 0; i < 100000; i++ {
+				if vf.Lookup([]byte(fmt.Sprintf("other%d", i))) {
+					falsePositives++
+				}
+			}
+			// 2 buckets of 4 slots with 8-bit fingerprints give about 8/255
+			if rate := float64(falsePositives) / 100000; rate > 0.04 {
+				t.Errorf("false positive rate = %.4f, want at most 0.04", rate)
+			}
+
+			for i := uint(0); i < n; i += 2 {
+				if !vf.Delete([]byte(fmt.Sprintf("item%d", i))) {
+					t.Fatalf("Delete() failed for item%d", i)
+				}
+			}
+			for i := uint(1); i < n; i += 2 {
+				if !vf.Lookup([]byte(fmt.Sprintf("item%d", i))) {
+					t.Fatalf("Lookup() false negative for item%d after deletes", i)
+				}
+			}
+		})
+	}
+}
+
+func TestVacuumFilter_MaxLoad(t *testing.T) {
+	vf, err := NewVacuumFilter(1000000, WithSeed(1))
+	if err != nil {
+		t.Fatalf("Failed to create VacuumFilter: %v", err)
+	}
+
+	for i := 0; ; i++ {
+		if err := vf.Insert([]byte(fmt.Sprintf("item%d", i))); err != nil {
+			break
+		}
+	}
+
+	if vf.LoadFactor() < VACUUM_LOAD_FACTOR-0.005 {
+		t.Errorf("LoadFactor() = %.4f when full, want about %.2f", vf.LoadFactor(), VACUUM_LOAD_FACTOR)
+	}
+}
+
+func TestVacuumFilter_MemoryAgainstCuckoo(t *testing.T) {
+	capacity := uint(1000000)
+	vf, _ := NewVacuumFilter(capacity)
+	cf, _ := NewCuckooFilter(capacity, 4, FINGERPRINT_SIZE_8)
+
+	if vf.SizeInBits()*3/2 > cf.SizeInBits() {
+		t.Errorf("SizeInBits() = %d, want well below the %d of CuckooFilter", vf.SizeInBits(), cf.SizeInBits())
+	}
+}
+
+func TestVacuumFilter_Deterministic(t *testing.T) {
+	run := func() *VacuumFilter {
+		vf, _ := NewVacuumFilter(5000, WithSeed(1))
+		for i := 0; i < 5000; i++ {
+			vf.Insert([]byte(fmt.Sprintf("item%d", i)))
+		}
+		return vf
+	}
+
+	first, second := run(), run()
+	if !reflect.DeepEqual(first.buckets, second.buckets) || first.victim != second.victim {
+		t.Error("buckets differ between runs with the same seed")
+	}
+}
+
+func BenchmarkVacuumFilter_Insert(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	var vf *VacuumFilter
+	for i := 0; i < b.N; i++ {
+		if i%benchmarkKeys == 0 {
+			vf, _ = NewVacuumFilter(benchmarkKeys)
+		}
+		vf.Insert(keys[i%benchmarkKeys])
+	}
+
+	b.ReportMetric(float64(vf.SizeInBits())/float64(benchmarkKeys), "bits/key")
+}
+
+// oddBenchmarkKeys is a capacity that is not a power of two, where power-of-two
+// tables waste the most space
+const oddBenchmarkKeys = 600000
+
+func BenchmarkVacuumFilter_InsertOddCapacity(b *testing.B) {
+	keys := makeKeys("item", oddBenchmarkKeys)
+	var vf *VacuumFilter
+	for i := 0; i < b.N; i++ {
+		if i%oddBenchmarkKeys == 0 {
+			vf, _ = NewVacuumFilter(oddBenchmarkKeys)
+		}
+		vf.Insert(keys[i%oddBenchmarkKeys])
+	}
+
+	b.ReportMetric(float64(vf.SizeInBits())/float64(oddBenchmarkKeys), "bits/key")
+}
+
+func BenchmarkCuckooFilter_InsertOddCapacity(b *testing.B) {
+	keys := makeKeys("item", oddBenchmarkKeys)
+	var cf *CuckooFilter
+	for i := 0; i < b.N; i++ {
+		if i%oddBenchmarkKeys == 0 {
+			cf, _ = NewCuckooFilter(oddBenchmarkKeys, 4, FINGERPRINT_SIZE_8)
+		}
+		cf.Insert(keys[i%oddBenchmarkKeys])
+	}
+
+	b.ReportMetric(float64(cf.SizeInBits())/float64(oddBenchmarkKeys), "bits/key")
+}
+
+func BenchmarkCuckooFilter_Insert(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	var cf *CuckooFilter
+	for i := 0; i < b.N; i++ {
+		if i%benchmarkKeys == 0 {
+			cf, _ = NewCuckooFilter(benchmarkKeys, 4, FINGERPRINT_SIZE_8)
+		}
+		cf.Insert(keys[i%benchmarkKeys])
+	}
+
+	b.ReportMetric(float64(cf.SizeInBits())/float64(benchmarkKeys), "bits/key")
+}
+
+func BenchmarkVacuumFilter_Lookup(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	vf, err := NewVacuumFilter(benchmarkKeys)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, key := range keys {
+		vf.Insert(key)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vf.Lookup(keys[i%benchmarkKeys])
+	}
+
+	b.ReportMetric(float64(vf.SizeInBits())/float64(vf.Count()), "bits/key")
+}
+
+func BenchmarkCuckooFilter_LookupSameCapacity(b *testing.B) {
+	keys := makeKeys("item", benchmarkKeys)
+	cf, err := NewCuckooFilter(benchmarkKeys, 4, FINGERPRINT_SIZE_8)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, key := range keys {
+		cf.Insert(key)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cf.Lookup(keys[i%benchmarkKeys])
+	}
+
+	b.ReportMetric(float64(cf.SizeInBits())/float64(cf.Count()), "bits/key")
+}
@@ -0,0 +1,295 @@
+package membership
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"slices"
+)
+
+const (
+	// XOR_FILTER_MAX_ITERATIONS is the number of seeds tried before construction fails
+	XOR_FILTER_MAX_ITERATIONS = 100
+
+	xorFilterHeaderSize = 12
+)
+
+// Xor8 implements a static xor filter (Graf & Lemire) with 8-bit fingerprints
+// It is built once from a known key set, uses about 9.84 bits per key and has a
+// false positive rate of about 1/256
+type Xor8 struct {
+	seed         uint64
+	blockLength  uint32
+	fingerprints []uint8
+}
+
+// Xor16 implements a static xor filter (Graf & Lemire) with 16-bit fingerprints
+// It is built once from a known key set, uses about 19.7 bits per key and has a
+// false positive rate of about 1/65536
+type Xor16 struct {
+	seed         uint64
+	blockLength  uint32
+	fingerprints []uint16
+}
+
+// xorKeyIndex is a peeled key hash together with the slot it was assigned to
+type xorKeyIndex struct {
+	hash  uint64
+	index uint32
+}
+
+type xorSet struct {
+	xorMask uint64
+	count   uint32
+}
+
+// NewXor8 builds an 8-bit xor filter from the given keys
+// Duplicate keys are ignored
+func NewXor8(keys [][]byte) (*Xor8, error) {
+	hashes, err := hashKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, blockLength, stack, err := xorConstruct(hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprints := make([]uint8, 3*blockLength)
+	for i := len(stack) - 1; i >= 0; i-- {
+		h0, h1, h2 := xorPositions(stack[i].hash, blockLength)
+		fingerprints[stack[i].index] = uint8(xorFingerprint(stack[i].hash)) ^ fingerprints[h0] ^ fingerprints[h1] ^ fingerprints[h2]
+	}
+
+	return &Xor8{seed: seed, blockLength: blockLength, fingerprints: fingerprints}, nil
+}
+
+// NewXor16 builds a 16-bit xor filter from the given keys
+// Duplicate keys are ignored
+func NewXor16(keys [][]byte) (*Xor16, error) {
+	hashes, err := hashKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, blockLength, stack, err := xorConstruct(hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprints := make([]uint16, 3*blockLength)
+	for i := len(stack) - 1; i >= 0; i-- {
+		h0, h1, h2 := xorPositions(stack[i].hash, blockLength)
+		fingerprints[stack[i].index] = uint16(xorFingerprint(stack[i].hash)) ^ fingerprints[h0] ^ fingerprints[h1] ^ fingerprints[h2]
+	}
+
+	return &Xor16{seed: seed, blockLength: blockLength, fingerprints: fingerprints}, nil
+}
+
+// Contains checks if an item might be in the filter
+// Returns true if item might be present, false if definitely not present
+func (xf *Xor8) Contains(item []byte) (bool, error) {
+	if err := validateInput(item); err != nil {
+		return false, err
+	}
+
+	hash := mixSplit(hash64(item), xf.seed)
+	h0, h1, h2 := xorPositions(hash, xf.blockLength)
+	return uint8(xorFingerprint(hash)) == xf.fingerprints[h0]^xf.fingerprints[h1]^xf.fingerprints[h2], nil
+}
+
+// Contains checks if an item might be in the filter
+// Returns true if item might be present, false if definitely not present
+func (xf *Xor16) Contains(item []byte) (bool, error) {
+	if err := validateInput(item); err != nil {
+		return false, err
+	}
+
+	hash := mixSplit(hash64(item), xf.seed)
+	h0, h1, h2 := xorPositions(hash, xf.blockLength)
+	return uint16(xorFingerprint(hash)) == xf.fingerprints[h0]^xf.fingerprints[h1]^xf.fingerprints[h2], nil
+}
+
+// Size returns the number of fingerprint slots in the filter
+func (xf *Xor8) Size() uint {
+	return uint(len(xf.fingerprints))
+}
+
+// Size returns the number of fingerprint slots in the filter
+func (xf *Xor16) Size() uint {
+	return uint(len(xf.fingerprints))
+}
+
+// MarshalBinary encodes the filter as seed, block length and fingerprints in little-endian order
+func (xf *Xor8) MarshalBinary() ([]byte, error) {
+	data := make([]byte, xorFilterHeaderSize, xorFilterHeaderSize+len(xf.fingerprints))
+	binary.LittleEndian.PutUint64(data[0:8], xf.seed)
+	binary.LittleEndian.PutUint32(data[8:12], xf.blockLength)
+	return append(data, xf.fingerprints...), nil
+}
+
+// UnmarshalBinary decodes a filter produced by MarshalBinary
+func (xf *Xor8) UnmarshalBinary(data []byte) error {
+	if len(data) < xorFilterHeaderSize {
+		return errors.New("data too short for xor filter header")
+	}
+
+	blockLength := binary.LittleEndian.Uint32(data[8:12])
+	if blockLength == 0 {
+		return errors.New("invalid xor filter block length")
+	}
+	if uint64(len(data)-xorFilterHeaderSize) != 3*uint64(blockLength) {
+		return errors.New("data length does not match xor filter block length")
+	}
+
+	xf.seed = binary.LittleEndian.Uint64(data[0:8])
+	xf.blockLength = blockLength
+	xf.fingerprints = append([]uint8(nil), data[xorFilterHeaderSize:]...)
+	return nil
+}
+
+// MarshalBinary encodes the filter as seed, block length and fingerprints in little-endian order
+func (xf *Xor16) MarshalBinary() ([]byte, error) {
+	data := make([]byte, xorFilterHeaderSize+2*len(xf.fingerprints))
+	binary.LittleEndian.PutUint64(data[0:8], xf.seed)
+	binary.LittleEndian.PutUint32(data[8:12], xf.blockLength)
+	for i, fp := range xf.fingerprints {
+		binary.LittleEndian.PutUint16(data[xorFilterHeaderSize+2*i:], fp)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes a filter produced by MarshalBinary
+func (xf *Xor16) UnmarshalBinary(data []byte) error {
+	if len(data) < xorFilterHeaderSize {
+		return errors.New("data too short for xor filter header")
+	}
+
+	blockLength := binary.LittleEndian.Uint32(data[8:12])
+	if blockLength == 0 {
+		return errors.New("invalid xor filter block length")
+	}
+	if uint64(len(data)-xorFilterHeaderSize) != 6*uint64(blockLength) {
+		return errors.New("data length does not match xor filter block length")
+	}
+
+	xf.seed = binary.LittleEndian.Uint64(data[0:8])
+	xf.blockLength = blockLength
+	xf.fingerprints = make([]uint16, 3*blockLength)
+	for i := range xf.fingerprints {
+		xf.fingerprints[i] = binary.LittleEndian.Uint16(data[xorFilterHeaderSize+2*i:])
+	}
+	return nil
+}
+
+// hashKeys validates the keys and returns their distinct 64-bit hashes in sorted order
+func hashKeys(keys [][]byte) ([]uint64, error) {
+	hashes := make([]uint64, len(keys))
+	for i, key := range keys {
+		if err := validateInput(key); err != nil {
+			return nil, err
+		}
+		hashes[i] = hash64(key)
+	}
+
+	slices.Sort(hashes)
+	return slices.Compact(hashes), nil
+}
+
+// xorConstruct finds a seed for which the 3-hypergraph of the keys can be peeled
+// and returns the peeling order. Fingerprints must be assigned in reverse order
+func xorConstruct(hashes []uint64) (uint64, uint32, []xorKeyIndex, error) {
+	capacity := 32 + uint32(1.23*float64(len(hashes)))
+	blockLength := capacity / 3
+
+	sets := make([]xorSet, 3*blockLength)
+	queue := make([]uint32, 0, 3*blockLength)
+	stack := make([]xorKeyIndex, 0, len(hashes))
+
+	rngState := uint64(0x726b2b9d438b9d4d)
+	for range XOR_FILTER_MAX_ITERATIONS {
+		seed := splitMix64(&rngState)
+		clear(sets)
+		queue = queue[:0]
+		stack = stack[:0]
+
+		for _, key := range hashes {
+			hash := mixSplit(key, seed)
+			h0, h1, h2 := xorPositions(hash, blockLength)
+			for _, h := range [3]uint32{h0, h1, h2} {
+				sets[h].xorMask ^= hash
+				sets[h].count++
+			}
+		}
+
+		for i := range sets {
+			if sets[i].count == 1 {
+				queue = append(queue, uint32(i))
+			}
+		}
+
+		for len(queue) > 0 {
+			index := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			if sets[index].count != 1 {
+				continue
+			}
+
+			hash := sets[index].xorMask
+			stack = append(stack, xorKeyIndex{hash: hash, index: index})
+
+			h0, h1, h2 := xorPositions(hash, blockLength)
+			for _, h := range [3]uint32{h0, h1, h2} {
+				sets[h].xorMask ^= hash
+				sets[h].count--
+				if sets[h].count == 1 {
+					queue = append(queue, h)
+				}
+			}
+		}
+
+		if len(stack) == len(hashes) {
+			return seed, blockLength, stack, nil
+		}
+	}
+
+	return 0, 0, nil, errors.New("failed to construct xor filter")
+}
+
+// xorPositions returns the slot of the hash in each of the three blocks
+func xorPositions(hash uint64, blockLength uint32) (uint32, uint32, uint32) {
+	h0 := reduce(uint32(hash), blockLength)
+	h1 := reduce(uint32(bits.RotateLeft64(hash, 21)), blockLength) + blockLength
+	h2 := reduce(uint32(bits.RotateLeft64(hash, 42)), blockLength) + 2*blockLength
+	return h0, h1, h2
+}
+
+func xorFingerprint(hash uint64) uint64 {
+	return hash ^ (hash >> 32)
+}
+
+// reduce maps a 32-bit hash uniformly onto [0, n) without a modulo
+func reduce(hash, n uint32) uint32 {
+	return uint32((uint64(hash) * uint64(n)) >> 32)
+}
+
+// mixSplit combines a key hash with a seed using the Murmur3 64-bit finalizer
+func mixSplit(key, seed uint64) uint64 {
+	h := key + seed
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// splitMix64 advances the state and returns the next pseudo-random seed
+func splitMix64(state *uint64) uint64 {
+	*state += 0x9e3779b97f4a7c15
+	z := *state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
@@ -0,0 +1,182 @@
+package membership
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewXor8(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    [][]byte
+		wantErr bool
+	}{
+		{
+			name:    "valid keys",
+			keys:    [][]byte{[]byte("a"), []byte("b"), []byte("c")},
+			wantErr: false,
+		},
+		{
+			name:    "no keys",
+			keys:    [][]byte{},
+			wantErr: false,
+		},
+		{
+			name:    "duplicate keys",
+			keys:    [][]byte{[]byte("a"), []byte("a"), []byte("b")},
+			wantErr: false,
+		},
+		{
+			name:    "empty key",
+			keys:    [][]byte{[]byte("a"), {}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewXor8(tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewXor8() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			_, err = NewXor16(tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewXor16() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestXor8_Contains(t *testing.T) {
+	keys := makeKeys("item", 100000)
+	xf, err := NewXor8(keys)
+	if err != nil {
+		t.Fatalf("Failed to create Xor8: %v", err)
+	}
+
+	for _, key := range keys {
+		if exists, _ := xf.Contains(key); !exists {
+			t.Fatalf("Contains() false negative for %s", key)
+		}
+	}
+
+	falsePositives := 0
+	trials := 200000
+	for i := 0; i < trials; i++ {
+		if exists, _ := xf.Contains([]byte(fmt.Sprintf("other%d", i))); exists {
+			falsePositives++
+		}
+	}
+
+	rate := float64(falsePositives) / float64(trials)
+	if rate > 0.006 {
+		t.Errorf("False positive rate too high: got %.5f, want about 1/256", rate)
+	}
+
+	bitsPerKey := float64(8*xf.Size()) / float64(len(keys))
+	if bitsPerKey > 10 {
+		t.Errorf("Bits per key too high: got %.2f, want about 9.84", bitsPerKey)
+	}
+
+	if _, err := xf.Contains(nil); err == nil {
+		t.Error("Contains() of nil item should return error")
+	}
+}
+
+func TestXor16_Contains(t *testing.T) {
+	keys := makeKeys("item", 100000)
+	xf, err := NewXor16(keys)
+	if err != nil {
+		t.Fatalf("Failed to create Xor16: %v", err)
+	}
+
+	for _, key := range keys {
+		if exists, _ := xf.Contains(key); !exists {
+			t.Fatalf("Contains() false negative for %s", key)
+		}
+	}
+
+	falsePositives := 0
+	trials := 200000
+	for i := 0; i < trials; i++ {
+		if exists, _ := xf.Contains([]byte(fmt.Sprintf("other%d", i))); exists {
+			falsePositives++
+		}
+	}
+
+	if falsePositives > 20 {
+		t.Errorf("Too many false positives: got %d in %d trials, want about 1/65536", falsePositives, trials)
+	}
+}
+
+func TestXor8_Serialization(t *testing.T) {
+	keys := makeKeys("item", 1000)
+	xf, err := NewXor8(keys)
+	if err != nil {
+		t.Fatalf("Failed to create Xor8: %v", err)
+	}
+
+	data, err := xf.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	decoded := &Xor8{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	for _, key := range keys {
+		if exists, _ := decoded.Contains(key); !exists {
+			t.Fatalf("Contains() false negative for %s after decoding", key)
+		}
+	}
+
+	// A zero block length, a missing fingerprint and an extra one are all rejected
+	for _, bad := range [][]byte{make([]byte, xorFilterHeaderSize), data[:len(data)-1], append(data, 0)} {
+		if err := decoded.UnmarshalBinary(bad); err == nil {
+			t.Errorf("UnmarshalBinary() of %d bytes should return error", len(bad))
+		}
+	}
+}
+
+func TestXor16_Serialization(t *testing.T) {
+	keys := makeKeys("item", 1000)
+	xf, err := NewXor16(keys)
+	if err != nil {
+		t.Fatalf("Failed to create Xor16: %v", err)
+	}
+
+	data, err := xf.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	decoded := &Xor16{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	for _, key := range keys {
+		if exists, _ := decoded.Contains(key); !exists {
+			t.Fatalf("Contains() false negative for %s after decoding", key)
+		}
+	}
+
+	if err := decoded.UnmarshalBinary(data[:5]); err == nil {
+		t.Error("UnmarshalBinary() of truncated header should return error")
+	}
+	for _, bad := range [][]byte{make([]byte, xorFilterHeaderSize), data[:len(data)-1], append(data, 0, 0)} {
+		if err := decoded.UnmarshalBinary(bad); err == nil {
+			t.Errorf("UnmarshalBinary() of %d bytes should return error", len(bad))
+		}
+	}
+}
+
+func makeKeys(prefix string, n int) [][]byte {
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("%s%d", prefix, i))
+	}
+	return keys
+}
@@ -0,0 +1,181 @@
+package reconciliation
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	ibltHeaderSize = 12
+
+	// maxFrameSize bounds the length of a frame read from a peer
+	maxFrameSize = 1 << 30
+)
+
+// MarshalBinary encodes the table as cell count, hash count and key size followed
+// by the count, checksum and key sum of every cell, in little-endian order
+func (t *IBLT) MarshalBinary() ([]byte, error) {
+	cellSize := 12 + int(t.keySize)
+	data := make([]byte, ibltHeaderSize+cellSize*len(t.cells))
+	binary.LittleEndian.PutUint32(data[0:4], uint32(t.cellCount))
+	binary.LittleEndian.PutUint32(data[4:8], uint32(t.hashCount))
+	binary.LittleEndian.PutUint32(data[8:12], uint32(t.keySize))
+
+	offset := ibltHeaderSize
+	for _, cell := range t.cells {
+		binary.LittleEndian.PutUint64(data[offset:], uint64(cell.count))
+		binary.LittleEndian.PutUint32(data[offset+8:], cell.hashSum)
+		copy(data[offset+12:offset+cellSize], cell.keySum)
+		offset += cellSize
+	}
+
+	return data, nil
+}
+
+// UnmarshalBinary decodes a table produced by MarshalBinary
+func (t *IBLT) UnmarshalBinary(data []byte) error {
+	if len(data) < ibltHeaderSize {
+		return errors.New("data too short for IBLT header")
+	}
+
+	cellCount := uint(binary.LittleEndian.Uint32(data[0:4]))
+	hashCount := uint(binary.LittleEndian.Uint32(data[4:8]))
+	keySize := uint(binary.LittleEndian.Uint32(data[8:12]))
+	if hashCount < 2 || cellCount < hashCount || cellCount%hashCount != 0 || keySize < 1 {
+		return errors.New("invalid IBLT header")
+	}
+
+	cellSize := 12 + uint64(keySize)
+	if uint64(len(data)-ibltHeaderSize) != cellSize*uint64(cellCount) {
+		return errors.New("data length does not match IBLT size")
+	}
+
+	table, err := NewIBLT(cellCount, hashCount, keySize)
+	if err != nil {
+		return err
+	}
+
+	offset := uint64(ibltHeaderSize)
+	for i := range table.cells {
+		table.cells[i].count = int64(binary.LittleEndian.Uint64(data[offset:]))
+		table.cells[i].hashSum = binary.LittleEndian.Uint32(data[offset+8:])
+		copy(table.cells[i].keySum, data[offset+12:offset+cellSize])
+		offset += cellSize
+	}
+
+	*t = *table
+	return nil
+}
+
+// MarshalBinary encodes the estimator as the number of strata followed by each
+// stratum's table, every table prefixed with its length
+func (se *StrataEstimator) MarshalBinary() ([]byte, error) {
+	data := binary.LittleEndian.AppendUint32(nil, uint32(len(se.strata)))
+	for _, table := range se.strata {
+		encoded, err := table.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(encoded)))
+		data = append(data, encoded...)
+	}
+
+	return data, nil
+}
+
+// UnmarshalBinary decodes an estimator produced by MarshalBinary
+func (se *StrataEstimator) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return errors.New("data too short for strata estimator header")
+	}
+
+	count := binary.LittleEndian.Uint32(data[0:4])
+	if count != STRATA_COUNT {
+		return errors.New("invalid number of strata")
+	}
+	data = data[4:]
+
+	strata := make([]*IBLT, count)
+	for i := range strata {
+		if len(data) < 4 {
+			return errors.New("data too short for stratum")
+		}
+		size := binary.LittleEndian.Uint32(data[0:4])
+		if uint64(len(data)-4) < uint64(size) {
+			return errors.New("data too short for stratum")
+		}
+
+		strata[i] = &IBLT{}
+		if err := strata[i].UnmarshalBinary(data[4 : 4+size]); err != nil {
+			return err
+		}
+		if i > 0 && strata[i].keySize != strata[0].keySize {
+			return errors.New("strata have different key sizes")
+		}
+		data = data[4+size:]
+	}
+
+	if len(data) != 0 {
+		return errors.New("trailing data after strata")
+	}
+
+	se.strata = strata
+	se.keySize = strata[0].keySize
+	return nil
+}
+
+// writeFrame writes data prefixed with its length
+func writeFrame(w io.Writer, data []byte) error {
+	header := binary.LittleEndian.AppendUint32(nil, uint32(len(data)))
+	if _, err := w.Write(append(header, data...)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// readFrame reads one length-prefixed frame
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.LittleEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return nil, errors.New("frame too large")
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// encodeKeys concatenates fixed-size keys
+func encodeKeys(keys [][]byte, keySize uint) []byte {
+	data := make([]byte, 0, uint(len(keys))*keySize)
+	for _, key := range keys {
+		data = append(data, key...)
+	}
+
+	return data
+}
+
+// decodeKeys splits data into keys of keySize bytes
+func decodeKeys(data []byte, keySize uint) ([][]byte, error) {
+	if uint(len(data))%keySize != 0 {
+		return nil, errors.New("data length is not a multiple of the key size")
+	}
+
+	keys := make([][]byte, 0, uint(len(data))/keySize)
+	for len(data) > 0 {
+		keys = append(keys, data[:keySize:keySize])
+		data = data[keySize:]
+	}
+
+	return keys, nil
+}
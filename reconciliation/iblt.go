@@ -0,0 +1,250 @@
+// Package reconciliation provides probabilistic data structures for set reconciliation
+package reconciliation
+
+import (
+	"errors"
+
+	"github.com/mrtkp9993/probdsgo/utils"
+)
+
+const (
+	// ibltChecksumSeed seeds the Murmur3 checksum that identifies pure cells
+	ibltChecksumSeed = 0x9747b28c
+)
+
+// ErrPeelingFailed is returned by ListEntries when the table holds more
+// differences than it can decode
+var ErrPeelingFailed = errors.New("failed to peel all entries from table")
+
+type ibltCell struct {
+	count   int64
+	keySum  []byte
+	hashSum uint32
+}
+
+// IBLT implements an Invertible Bloom Lookup Table (Goodrich & Mitzenmacher) over
+// fixed-size keys. Each key is added to one cell in each of hashCount subtables,
+// and every cell keeps the number of keys, the xor of the keys and the xor of their
+// checksums. Subtracting the tables of two sets cancels the common keys, and the
+// symmetric difference can then be listed as long as it is small relative to the
+// number of cells
+type IBLT struct {
+	cells         []ibltCell
+	cellCount     uint
+	subtableSize  uint
+	hashCount     uint
+	keySize       uint
+	hashFunctions []*utils.Murmur3
+}
+
+// NewIBLT creates a new empty IBLT
+// cellCount: number of cells, rounded up to a multiple of hashCount
+// hashCount: number of cells each key is added to, at least 2
+// keySize: length of every key in bytes
+func NewIBLT(cellCount, hashCount, keySize uint) (*IBLT, error) {
+	if hashCount < 2 || cellCount < hashCount {
+		return nil, errors.New("invalid cell count or hash count")
+	}
+
+	if keySize < 1 {
+		return nil, errors.New("invalid key size")
+	}
+
+	subtableSize := (cellCount + hashCount - 1) / hashCount
+	cells := make([]ibltCell, subtableSize*hashCount)
+	for i := range cells {
+		cells[i].keySum = make([]byte, keySize)
+	}
+
+	hashFunctions := make([]*utils.Murmur3, hashCount)
+	for i := range hashCount {
+		hashFunctions[i] = utils.NewMurmur3WithSeed(uint32(i + 1))
+	}
+
+	return &IBLT{
+		cells:         cells,
+		cellCount:     subtableSize * hashCount,
+		subtableSize:  subtableSize,
+		hashCount:     hashCount,
+		keySize:       keySize,
+		hashFunctions: hashFunctions,
+	}, nil
+}
+
+// Insert adds a key to the table
+// Returns error if the key does not have the table's key size
+func (t *IBLT) Insert(key []byte) error {
+	if err := t.validateKey(key); err != nil {
+		return err
+	}
+
+	t.update(key, 1)
+	return nil
+}
+
+// Delete removes a key from the table
+// Deleting a key that was never inserted records it as a negative entry
+func (t *IBLT) Delete(key []byte) error {
+	if err := t.validateKey(key); err != nil {
+		return err
+	}
+
+	t.update(key, -1)
+	return nil
+}
+
+// Subtract returns a new table holding the difference of the two tables. Keys
+// present in both cancel out; the keys only in t have count 1 and the keys only
+// in other have count -1
+func (t *IBLT) Subtract(other *IBLT) (*IBLT, error) {
+	if t.cellCount != other.cellCount || t.hashCount != other.hashCount || t.keySize != other.keySize {
+		return nil, errors.New("cannot subtract: tables have different parameters")
+	}
+
+	result := t.clone()
+	for i := range result.cells {
+		cell := &result.cells[i]
+		cell.count -= other.cells[i].count
+		xorBytes(cell.keySum, other.cells[i].keySum)
+		cell.hashSum ^= other.cells[i].hashSum
+	}
+
+	return result, nil
+}
+
+// ListEntries peels the table and returns the keys with a positive count and the
+// keys with a negative count. On a subtracted table these are the keys only in
+// the first and only in the second set. The table itself is not modified
+// Returns ErrPeelingFailed together with the entries found so far if the table
+// could not be fully decoded
+func (t *IBLT) ListEntries() ([][]byte, [][]byte, error) {
+	work := t.clone()
+
+	var added, removed [][]byte
+	queue := make([]uint, 0, len(work.cells))
+	for i := range work.cells {
+		queue = append(queue, uint(i))
+	}
+
+	for len(queue) > 0 {
+		index := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if !work.isPure(index) {
+			continue
+		}
+
+		cell := &work.cells[index]
+		key := append([]byte(nil), cell.keySum...)
+		sign := cell.count
+		if sign == 1 {
+			added = append(added, key)
+		} else {
+			removed = append(removed, key)
+		}
+
+		for _, position := range work.positions(key) {
+			work.apply(position, key, -sign)
+			queue = append(queue, position)
+		}
+	}
+
+	for i := range work.cells {
+		if !work.isEmpty(uint(i)) {
+			return added, removed, ErrPeelingFailed
+		}
+	}
+
+	return added, removed, nil
+}
+
+// Size returns the number of cells in the table
+func (t *IBLT) Size() uint {
+	return t.cellCount
+}
+
+// KeySize returns the length of the keys in bytes
+func (t *IBLT) KeySize() uint {
+	return t.keySize
+}
+
+// positions returns the cell of the key in each subtable
+func (t *IBLT) positions(key []byte) []uint {
+	positions := make([]uint, t.hashCount)
+	for i, hashFunc := range t.hashFunctions {
+		positions[i] = uint(i)*t.subtableSize + uint(hashFunc.Hash(key))%t.subtableSize
+	}
+
+	return positions
+}
+
+func (t *IBLT) update(key []byte, delta int64) {
+	for _, position := range t.positions(key) {
+		t.apply(position, key, delta)
+	}
+}
+
+func (t *IBLT) apply(position uint, key []byte, delta int64) {
+	cell := &t.cells[position]
+	cell.count += delta
+	xorBytes(cell.keySum, key)
+	cell.hashSum ^= utils.Murmur3_32(key, ibltChecksumSeed)
+}
+
+// isPure reports whether a cell holds exactly one key, checked by its checksum
+// and by the key mapping back to the cell
+func (t *IBLT) isPure(index uint) bool {
+	cell := &t.cells[index]
+	if cell.count != 1 && cell.count != -1 {
+		return false
+	}
+
+	if cell.hashSum != utils.Murmur3_32(cell.keySum, ibltChecksumSeed) {
+		return false
+	}
+
+	subtable := index / t.subtableSize
+	return uint(t.hashFunctions[subtable].Hash(cell.keySum))%t.subtableSize == index%t.subtableSize
+}
+
+func (t *IBLT) isEmpty(index uint) bool {
+	cell := &t.cells[index]
+	if cell.count != 0 || cell.hashSum != 0 {
+		return false
+	}
+
+	for _, b := range cell.keySum {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (t *IBLT) validateKey(key []byte) error {
+	if uint(len(key)) != t.keySize {
+		return errors.New("key size does not match table key size")
+	}
+
+	return nil
+}
+
+func (t *IBLT) clone() *IBLT {
+	result := *t
+	result.cells = make([]ibltCell, len(t.cells))
+	for i, cell := range t.cells {
+		result.cells[i] = ibltCell{
+			count:   cell.count,
+			keySum:  append([]byte(nil), cell.keySum...),
+			hashSum: cell.hashSum,
+		}
+	}
+
+	return &result
+}
+
+func xorBytes(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
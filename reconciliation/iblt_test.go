@@ -0,0 +1,174 @@
+package reconciliation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// makeKey returns an 8-byte key for the integer i
+func makeKey(i uint64) []byte {
+	key := make([]byte, 8)
+	binary.LittleEndian.PutUint64(key, i)
+	return key
+}
+
+func sortKeys(keys [][]byte) [][]byte {
+	slices.SortFunc(keys, bytes.Compare)
+	return keys
+}
+
+func TestNewIBLT(t *testing.T) {
+	tests := []struct {
+		name        string
+		cellCount   uint
+		hashCount   uint
+		keySize     uint
+		wantSize    uint
+		shouldError bool
+	}{
+		{
+			name:        "Valid parameters",
+			cellCount:   100,
+			hashCount:   4,
+			keySize:     8,
+			wantSize:    100,
+			shouldError: false,
+		},
+		{
+			name:        "Cell count rounded up",
+			cellCount:   100,
+			hashCount:   3,
+			keySize:     8,
+			wantSize:    102,
+			shouldError: false,
+		},
+		{
+			name:        "Single hash function",
+			cellCount:   100,
+			hashCount:   1,
+			keySize:     8,
+			shouldError: true,
+		},
+		{
+			name:        "Zero key size",
+			cellCount:   100,
+			hashCount:   3,
+			keySize:     0,
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := NewIBLT(tt.cellCount, tt.hashCount, tt.keySize)
+			if tt.shouldError {
+				if err == nil {
+					t.Error("NewIBLT() error = nil, expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewIBLT() error = %v, expected no error", err)
+			}
+			if table.Size() != tt.wantSize {
+				t.Errorf("Size() = %d, want %d", table.Size(), tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestIBLT_InsertDelete(t *testing.T) {
+	table, _ := NewIBLT(60, 3, 8)
+
+	if err := table.Insert([]byte("short")); err == nil {
+		t.Error("Insert() with wrong key size should return error")
+	}
+
+	for i := uint64(0); i < 20; i++ {
+		table.Insert(makeKey(i))
+	}
+	for i := uint64(0); i < 15; i++ {
+		table.Delete(makeKey(i))
+	}
+	table.Delete(makeKey(100))
+
+	added, removed, err := table.ListEntries()
+	if err != nil {
+		t.Fatalf("ListEntries() error = %v", err)
+	}
+
+	var wantAdded [][]byte
+	for i := uint64(15); i < 20; i++ {
+		wantAdded = append(wantAdded, makeKey(i))
+	}
+	if !slices.EqualFunc(sortKeys(added), sortKeys(wantAdded), bytes.Equal) {
+		t.Errorf("ListEntries() added = %v, want %v", added, wantAdded)
+	}
+	if len(removed) != 1 || !bytes.Equal(removed[0], makeKey(100)) {
+		t.Errorf("ListEntries() removed = %v, want [%v]", removed, makeKey(100))
+	}
+
+	// Listing does not modify the table
+	if again, _, _ := table.ListEntries(); len(again) != len(wantAdded) {
+		t.Errorf("second ListEntries() returned %d entries, want %d", len(again), len(wantAdded))
+	}
+}
+
+func TestIBLT_Subtract(t *testing.T) {
+	a, _ := NewIBLT(120, 4, 8)
+	b, _ := NewIBLT(120, 4, 8)
+
+	// 10000 shared keys, 30 only in a and 20 only in b
+	for i := uint64(0); i < 10000; i++ {
+		a.Insert(makeKey(i))
+		b.Insert(makeKey(i))
+	}
+	for i := uint64(0); i < 30; i++ {
+		a.Insert(makeKey(20000 + i))
+	}
+	for i := uint64(0); i < 20; i++ {
+		b.Insert(makeKey(30000 + i))
+	}
+
+	difference, err := a.Subtract(b)
+	if err != nil {
+		t.Fatalf("Subtract() error = %v", err)
+	}
+
+	onlyA, onlyB, err := difference.ListEntries()
+	if err != nil {
+		t.Fatalf("ListEntries() error = %v", err)
+	}
+	if len(onlyA) != 30 || len(onlyB) != 20 {
+		t.Fatalf("ListEntries() returned %d and %d entries, want 30 and 20", len(onlyA), len(onlyB))
+	}
+	for _, key := range onlyA {
+		if i := binary.LittleEndian.Uint64(key); i < 20000 || i >= 20030 {
+			t.Errorf("unexpected key %d only in a", i)
+		}
+	}
+	for _, key := range onlyB {
+		if i := binary.LittleEndian.Uint64(key); i < 30000 || i >= 30020 {
+			t.Errorf("unexpected key %d only in b", i)
+		}
+	}
+
+	other, _ := NewIBLT(60, 4, 8)
+	if _, err := a.Subtract(other); err == nil {
+		t.Error("Subtract() of incompatible tables should return error")
+	}
+}
+
+func TestIBLT_PeelingFailure(t *testing.T) {
+	table, _ := NewIBLT(30, 3, 8)
+	for i := uint64(0); i < 200; i++ {
+		table.Insert(makeKey(i))
+	}
+
+	if _, _, err := table.ListEntries(); !errors.Is(err, ErrPeelingFailed) {
+		t.Errorf("ListEntries() error = %v, want ErrPeelingFailed", err)
+	}
+}
@@ -0,0 +1,249 @@
+package reconciliation
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// RECONCILIATION_MAX_ATTEMPTS is the number of table sizes tried before giving up
+	RECONCILIATION_MAX_ATTEMPTS = 8
+
+	// RECONCILIATION_HASH_COUNT is the number of hash functions of the exchanged tables
+	RECONCILIATION_HASH_COUNT = 3
+
+	statusRetry   = 0
+	statusDecoded = 1
+	statusTable   = 2
+	statusFailed  = 3
+)
+
+// Reconciler computes the symmetric difference between its key set and the key
+// set of a peer while exchanging data proportional to the size of the difference.
+//
+// The initiator sends a strata estimator of its keys. The responder estimates the
+// difference size, and sends an IBLT sized for it. The initiator subtracts its own
+// table and peels the difference; if peeling fails it asks for a table twice as
+// large, otherwise it sends the decoded difference back so both sides learn it.
+// Every message starts with a status byte, and a side that fails sends
+// statusFailed so that its peer returns an error instead of waiting
+type Reconciler struct {
+	keys [][]byte
+	// present holds the added keys; a key inserted twice would cancel out of the tables
+	present map[string]struct{}
+	keySize uint
+	strata  *StrataEstimator
+}
+
+// NewReconciler creates a new reconciler for keys of the given size in bytes
+func NewReconciler(keySize uint) (*Reconciler, error) {
+	strata, err := NewStrataEstimator(keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reconciler{present: make(map[string]struct{}), keySize: keySize, strata: strata}, nil
+}
+
+// Add adds a key to the local set. Adding a key that is already in the set does
+// nothing, since the tables XOR keys together and a second copy would remove it
+// Returns error if the key does not have the reconciler's key size
+func (r *Reconciler) Add(key []byte) error {
+	if _, ok := r.present[string(key)]; ok {
+		return nil
+	}
+
+	if err := r.strata.Insert(key); err != nil {
+		return err
+	}
+
+	r.present[string(key)] = struct{}{}
+	r.keys = append(r.keys, append([]byte(nil), key...))
+	return nil
+}
+
+// Count returns the number of keys in the local set
+func (r *Reconciler) Count() uint {
+	return uint(len(r.keys))
+}
+
+// CellsForDifference returns the number of IBLT cells used for an expected
+// difference of d keys. The constant term covers the poor peeling of small tables
+func CellsForDifference(d uint) uint {
+	return 2*d + 32
+}
+
+// Initiate runs the initiator side of the protocol over rw
+// Returns the keys only in the local set and the keys only in the peer's set
+func (r *Reconciler) Initiate(rw io.ReadWriter) ([][]byte, [][]byte, error) {
+	encoded, err := r.strata.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writeFrame(rw, encoded); err != nil {
+		return nil, nil, err
+	}
+
+	for range RECONCILIATION_MAX_ATTEMPTS {
+		data, err := readStatus(rw, statusTable)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		remote := &IBLT{}
+		if err := remote.UnmarshalBinary(data); err != nil {
+			return fail(rw, err)
+		}
+		if remote.keySize != r.keySize {
+			return fail(rw, errors.New("peer uses a different key size"))
+		}
+
+		local, err := r.table(remote.cellCount)
+		if err != nil {
+			return fail(rw, err)
+		}
+
+		difference, err := local.Subtract(remote)
+		if err != nil {
+			return fail(rw, err)
+		}
+
+		onlyLocal, onlyRemote, err := difference.ListEntries()
+		if errors.Is(err, ErrPeelingFailed) {
+			if err := writeFrame(rw, []byte{statusRetry}); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		// The peer's view of the difference is the mirror image of ours
+		message := append([]byte{statusDecoded}, encodeKeys(onlyRemote, r.keySize)...)
+		if err := writeFrame(rw, message); err != nil {
+			return nil, nil, err
+		}
+		if err := writeFrame(rw, encodeKeys(onlyLocal, r.keySize)); err != nil {
+			return nil, nil, err
+		}
+
+		return onlyLocal, onlyRemote, nil
+	}
+
+	return nil, nil, fmt.Errorf("reconciliation failed after %d attempts", RECONCILIATION_MAX_ATTEMPTS)
+}
+
+// Respond runs the responder side of the protocol over rw
+// Returns the keys only in the local set and the keys only in the peer's set
+func (r *Reconciler) Respond(rw io.ReadWriter) ([][]byte, [][]byte, error) {
+	data, err := readFrame(rw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	remote := &StrataEstimator{}
+	if err := remote.UnmarshalBinary(data); err != nil {
+		return fail(rw, err)
+	}
+
+	estimate, err := r.strata.Estimate(remote)
+	if err != nil {
+		return fail(rw, err)
+	}
+
+	cellCount := CellsForDifference(estimate)
+	for range RECONCILIATION_MAX_ATTEMPTS {
+		table, err := r.table(cellCount)
+		if err != nil {
+			return fail(rw, err)
+		}
+
+		encoded, err := table.MarshalBinary()
+		if err != nil {
+			return fail(rw, err)
+		}
+		if err := writeFrame(rw, append([]byte{statusTable}, encoded...)); err != nil {
+			return nil, nil, err
+		}
+
+		message, err := readFrame(rw)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(message) == 1 && message[0] == statusRetry {
+			cellCount *= 2
+			continue
+		}
+		if err := checkStatus(message, statusDecoded); err != nil {
+			return nil, nil, err
+		}
+
+		onlyLocal, err := decodeKeys(message[1:], r.keySize)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		data, err := readFrame(rw)
+		if err != nil {
+			return nil, nil, err
+		}
+		onlyRemote, err := decodeKeys(data, r.keySize)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return onlyLocal, onlyRemote, nil
+	}
+
+	return nil, nil, fmt.Errorf("reconciliation failed after %d attempts", RECONCILIATION_MAX_ATTEMPTS)
+}
+
+// readStatus reads a frame that must start with the given status
+// Returns the rest of the frame
+func readStatus(r io.Reader, want byte) ([]byte, error) {
+	message, err := readFrame(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStatus(message, want); err != nil {
+		return nil, err
+	}
+
+	return message[1:], nil
+}
+
+// checkStatus returns an error unless the message starts with the given status
+func checkStatus(message []byte, want byte) error {
+	switch {
+	case len(message) == 0:
+		return errors.New("empty message from peer")
+	case message[0] == statusFailed:
+		return errors.New("peer failed to reconcile")
+	case message[0] != want:
+		return fmt.Errorf("unexpected status %d from peer", message[0])
+	}
+
+	return nil
+}
+
+// fail tells the peer that reconciliation failed and returns err. An error
+// writing the status is dropped in favour of err
+func fail(w io.Writer, err error) ([][]byte, [][]byte, error) {
+	writeFrame(w, []byte{statusFailed})
+	return nil, nil, err
+}
+
+// table builds an IBLT of the local keys with the given number of cells
+func (r *Reconciler) table(cellCount uint) (*IBLT, error) {
+	table, err := NewIBLT(cellCount, RECONCILIATION_HASH_COUNT, r.keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range r.keys {
+		if err := table.Insert(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return table, nil
+}
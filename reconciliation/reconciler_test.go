@@ -0,0 +1,248 @@
+package reconciliation
+
+import (
+	"bytes"
+	"net"
+	"slices"
+	"testing"
+)
+
+type reconcileResult struct {
+	onlyLocal  [][]byte
+	onlyRemote [][]byte
+	err        error
+}
+
+// reconcile runs both sides of the protocol over an in-memory pipe. The pipe is
+// only closed once both sides return, so a side that fails must tell its peer
+func reconcile(initiator, responder *Reconciler) (reconcileResult, reconcileResult) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	done := make(chan reconcileResult)
+	go func() {
+		onlyLocal, onlyRemote, err := responder.Respond(b)
+		done <- reconcileResult{onlyLocal, onlyRemote, err}
+	}()
+
+	onlyLocal, onlyRemote, err := initiator.Initiate(a)
+	return reconcileResult{onlyLocal, onlyRemote, err}, <-done
+}
+
+func keyRange(from, to uint64) [][]byte {
+	var keys [][]byte
+	for i := from; i < to; i++ {
+		keys = append(keys, makeKey(i))
+	}
+	return keys
+}
+
+func TestReconciler(t *testing.T) {
+	tests := []struct {
+		name      string
+		onlyLocal int
+		onlyPeer  int
+	}{
+		{name: "identical sets", onlyLocal: 0, onlyPeer: 0},
+		{name: "one side", onlyLocal: 25, onlyPeer: 0},
+		{name: "both sides", onlyLocal: 40, onlyPeer: 60},
+		{name: "large difference", onlyLocal: 1500, onlyPeer: 2500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initiator, err := NewReconciler(8)
+			if err != nil {
+				t.Fatalf("NewReconciler() error = %v", err)
+			}
+			responder, _ := NewReconciler(8)
+
+			for _, key := range keyRange(0, 5000) {
+				initiator.Add(key)
+				responder.Add(key)
+			}
+			wantLocal := sortKeys(keyRange(100000, 100000+uint64(tt.onlyLocal)))
+			wantPeer := sortKeys(keyRange(200000, 200000+uint64(tt.onlyPeer)))
+			for _, key := range wantLocal {
+				initiator.Add(key)
+			}
+			for _, key := range wantPeer {
+				responder.Add(key)
+			}
+
+			got, peer := reconcile(initiator, responder)
+			if got.err != nil || peer.err != nil {
+				t.Fatalf("reconcile() errors = %v, %v", got.err, peer.err)
+			}
+
+			if !slices.EqualFunc(sortKeys(got.onlyLocal), wantLocal, bytes.Equal) {
+				t.Errorf("initiator only local = %d keys, want %d", len(got.onlyLocal), len(wantLocal))
+			}
+			if !slices.EqualFunc(sortKeys(got.onlyRemote), wantPeer, bytes.Equal) {
+				t.Errorf("initiator only remote = %d keys, want %d", len(got.onlyRemote), len(wantPeer))
+			}
+			if !slices.EqualFunc(sortKeys(peer.onlyLocal), wantPeer, bytes.Equal) {
+				t.Errorf("responder only local = %d keys, want %d", len(peer.onlyLocal), len(wantPeer))
+			}
+			if !slices.EqualFunc(sortKeys(peer.onlyRemote), wantLocal, bytes.Equal) {
+				t.Errorf("responder only remote = %d keys, want %d", len(peer.onlyRemote), len(wantLocal))
+			}
+		})
+	}
+}
+
+func TestReconciler_DuplicateKeys(t *testing.T) {
+	initiator, _ := NewReconciler(8)
+	responder, _ := NewReconciler(8)
+
+	for _, key := range keyRange(0, 100) {
+		initiator.Add(key)
+		responder.Add(key)
+	}
+	// A key added twice stays in the difference instead of cancelling out
+	for range 2 {
+		if err := initiator.Add(makeKey(500)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	if initiator.Count() != 101 {
+		t.Errorf("Count() = %d, want 101", initiator.Count())
+	}
+
+	got, peer := reconcile(initiator, responder)
+	if got.err != nil || peer.err != nil {
+		t.Fatalf("reconcile() errors = %v, %v", got.err, peer.err)
+	}
+	if len(got.onlyLocal) != 1 || !bytes.Equal(got.onlyLocal[0], makeKey(500)) || len(peer.onlyRemote) != 1 {
+		t.Errorf("reconcile() found %d and %d keys, want the duplicated key", len(got.onlyLocal), len(peer.onlyRemote))
+	}
+}
+
+func TestReconciler_Retry(t *testing.T) {
+	initiator, _ := NewReconciler(8)
+	responder, _ := NewReconciler(8)
+
+	for _, key := range keyRange(0, 300) {
+		initiator.Add(key)
+	}
+
+	// Start the responder with a table far too small by hiding the difference
+	// from its estimator, so the first attempts must fail to peel
+	responder.strata, _ = NewStrataEstimator(8)
+	for _, key := range keyRange(0, 300) {
+		responder.strata.Insert(key)
+	}
+
+	got, peer := reconcile(initiator, responder)
+	if got.err != nil || peer.err != nil {
+		t.Fatalf("reconcile() errors = %v, %v", got.err, peer.err)
+	}
+	if len(got.onlyLocal) != 300 || len(peer.onlyRemote) != 300 {
+		t.Errorf("reconcile() found %d and %d keys, want 300", len(got.onlyLocal), len(peer.onlyRemote))
+	}
+}
+
+func TestReconciler_KeySizeMismatch(t *testing.T) {
+	initiator, _ := NewReconciler(8)
+	responder, _ := NewReconciler(16)
+	initiator.Add(makeKey(1))
+
+	if err := responder.Add(makeKey(1)); err == nil {
+		t.Error("Add() with wrong key size should return error")
+	}
+
+	got, peer := reconcile(initiator, responder)
+	if got.err == nil || peer.err == nil {
+		t.Errorf("reconcile() errors = %v, %v, want both to fail", got.err, peer.err)
+	}
+}
+
+func TestReconciler_UnexpectedStatus(t *testing.T) {
+	for _, status := range []byte{statusTable, statusFailed, 7} {
+		responder, _ := NewReconciler(8)
+		responder.Add(makeKey(1))
+
+		a, b := net.Pipe()
+		done := make(chan error)
+		go func() {
+			_, _, err := responder.Respond(b)
+			done <- err
+		}()
+
+		// Act as the initiator, answering the first table with the status
+		strata, _ := NewStrataEstimator(8)
+		encoded, _ := strata.MarshalBinary()
+		writeFrame(a, encoded)
+		if _, err := readStatus(a, statusTable); err != nil {
+			t.Fatalf("readStatus() error = %v", err)
+		}
+		writeFrame(a, []byte{status})
+
+		if err := <-done; err == nil {
+			t.Errorf("Respond() after status %d should return error", status)
+		}
+		a.Close()
+		b.Close()
+	}
+}
+
+func TestIBLT_MarshalBinary(t *testing.T) {
+	table, _ := NewIBLT(60, 3, 8)
+	for i := uint64(0); i < 10; i++ {
+		table.Insert(makeKey(i))
+	}
+	table.Delete(makeKey(99))
+
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	decoded := &IBLT{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	added, removed, err := decoded.ListEntries()
+	if err != nil || len(added) != 10 || len(removed) != 1 {
+		t.Errorf("ListEntries() after decoding = %d, %d, %v", len(added), len(removed), err)
+	}
+
+	for _, bad := range [][]byte{nil, data[:20], append(data, 0)} {
+		if err := decoded.UnmarshalBinary(bad); err == nil {
+			t.Errorf("UnmarshalBinary() of %d bytes should return error", len(bad))
+		}
+	}
+}
+
+func TestStrataEstimator_MarshalBinary(t *testing.T) {
+	a, _ := NewStrataEstimator(8)
+	b, _ := NewStrataEstimator(8)
+	for i := uint64(0); i < 1000; i++ {
+		a.Insert(makeKey(i))
+		if i%10 != 0 {
+			b.Insert(makeKey(i))
+		}
+	}
+
+	data, err := b.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	decoded := &StrataEstimator{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	want, _ := a.Estimate(b)
+	got, _ := a.Estimate(decoded)
+	if got != want {
+		t.Errorf("Estimate() with decoded estimator = %d, want %d", got, want)
+	}
+
+	if err := decoded.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("UnmarshalBinary() of truncated data should return error")
+	}
+}
@@ -0,0 +1,90 @@
+package reconciliation
+
+import (
+	"errors"
+	"math/bits"
+
+	"github.com/mrtkp9993/probdsgo/utils"
+)
+
+const (
+	// STRATA_COUNT is the number of strata, enough for differences up to 2^32
+	STRATA_COUNT = 32
+
+	// STRATA_CELL_COUNT is the number of cells in the IBLT of each stratum
+	STRATA_CELL_COUNT = 80
+
+	strataHashCount = 4
+	strataSeed      = 0x2f8a6c1d
+)
+
+// StrataEstimator estimates the size of the symmetric difference of two sets
+// (Eppstein et al.). Keys are assigned to stratum i with probability 2^-(i+1) and
+// each stratum is a small IBLT. Starting from the sparsest stratum, the strata of
+// two estimators are subtracted and decoded; when a stratum fails to decode, the
+// count so far is scaled up by the sampling rate of the strata decoded
+type StrataEstimator struct {
+	strata  []*IBLT
+	keySize uint
+}
+
+// NewStrataEstimator creates a new strata estimator for keys of the given size in bytes
+func NewStrataEstimator(keySize uint) (*StrataEstimator, error) {
+	strata := make([]*IBLT, STRATA_COUNT)
+	for i := range strata {
+		table, err := NewIBLT(STRATA_CELL_COUNT, strataHashCount, keySize)
+		if err != nil {
+			return nil, err
+		}
+		strata[i] = table
+	}
+
+	return &StrataEstimator{strata: strata, keySize: keySize}, nil
+}
+
+// Insert adds a key to its stratum
+// Returns error if the key does not have the estimator's key size
+func (se *StrataEstimator) Insert(key []byte) error {
+	return se.strata[stratum(key)].Insert(key)
+}
+
+// Delete removes a key from its stratum
+func (se *StrataEstimator) Delete(key []byte) error {
+	return se.strata[stratum(key)].Delete(key)
+}
+
+// Estimate returns the estimated size of the symmetric difference between the
+// sets held by the two estimators
+func (se *StrataEstimator) Estimate(other *StrataEstimator) (uint, error) {
+	if se.keySize != other.keySize || len(se.strata) != len(other.strata) {
+		return 0, errors.New("cannot estimate: estimators have different parameters")
+	}
+
+	count := uint(0)
+	for i := len(se.strata) - 1; i >= 0; i-- {
+		difference, err := se.strata[i].Subtract(other.strata[i])
+		if err != nil {
+			return 0, err
+		}
+
+		added, removed, err := difference.ListEntries()
+		if err != nil {
+			// Strata i+1 and above hold a 2^-(i+1) sample of the difference
+			return count << (i + 1), nil
+		}
+		count += uint(len(added) + len(removed))
+	}
+
+	return count, nil
+}
+
+// KeySize returns the length of the keys in bytes
+func (se *StrataEstimator) KeySize() uint {
+	return se.keySize
+}
+
+// stratum returns the number of trailing zeros of the key hash, so stratum i
+// receives a key with probability 2^-(i+1)
+func stratum(key []byte) int {
+	return min(bits.TrailingZeros32(utils.Murmur3_32(key, strataSeed)), STRATA_COUNT-1)
+}
@@ -0,0 +1,67 @@
+package reconciliation
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStrataEstimator_Estimate(t *testing.T) {
+	for _, difference := range []int{0, 10, 100, 1000, 10000} {
+		t.Run(fmt.Sprintf("difference %d", difference), func(t *testing.T) {
+			a, err := NewStrataEstimator(8)
+			if err != nil {
+				t.Fatalf("NewStrataEstimator() error = %v", err)
+			}
+			b, _ := NewStrataEstimator(8)
+
+			for i := uint64(0); i < 20000; i++ {
+				a.Insert(makeKey(i))
+				b.Insert(makeKey(i))
+			}
+			for i := 0; i < difference; i++ {
+				if i%2 == 0 {
+					a.Insert(makeKey(uint64(100000 + i)))
+				} else {
+					b.Insert(makeKey(uint64(100000 + i)))
+				}
+			}
+
+			estimate, err := a.Estimate(b)
+			if err != nil {
+				t.Fatalf("Estimate() error = %v", err)
+			}
+
+			// Small differences decode exactly, large ones are sampled
+			low, high := float64(difference)/2, float64(difference)*2
+			if float64(estimate) < low || float64(estimate) > high {
+				t.Errorf("Estimate() = %d, want within a factor 2 of %d", estimate, difference)
+			}
+		})
+	}
+}
+
+func TestStrataEstimator_Delete(t *testing.T) {
+	a, _ := NewStrataEstimator(8)
+	b, _ := NewStrataEstimator(8)
+
+	for i := uint64(0); i < 100; i++ {
+		a.Insert(makeKey(i))
+		b.Insert(makeKey(i))
+	}
+	for i := uint64(0); i < 5; i++ {
+		a.Delete(makeKey(i))
+	}
+
+	if estimate, _ := a.Estimate(b); estimate != 5 {
+		t.Errorf("Estimate() = %d, want 5", estimate)
+	}
+
+	if err := a.Insert([]byte("bad")); err == nil {
+		t.Error("Insert() with wrong key size should return error")
+	}
+
+	other, _ := NewStrataEstimator(16)
+	if _, err := a.Estimate(other); err == nil {
+		t.Error("Estimate() with different key sizes should return error")
+	}
+}
@@ -0,0 +1,255 @@
+// Package similarity provides probabilistic data structures for similarity search
+package similarity
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// RandomHyperplaneLSH implements sign random projection locality-sensitive hashing
+// for cosine similarity. Each table hashes a vector to a numBits signature where
+// bit i is the sign of the dot product with the i-th random hyperplane, so two
+// vectors agree on a bit with probability 1 - theta/pi
+type RandomHyperplaneLSH struct {
+	dim       uint
+	numTables uint
+	numBits   uint
+	seed      int64
+	planes    [][]float32
+	tables    []map[uint64][]uint64
+	count     uint
+}
+
+// NewRandomHyperplaneLSH creates a new LSH index for vectors of the given dimension
+// dim: dimension of the indexed vectors
+// numTables: number of independent hash tables
+// numBits: number of hyperplanes per table (signature length, at most 64)
+// seed: seed for the random hyperplanes, identical seeds give identical projections
+func NewRandomHyperplaneLSH(dim, numTables, numBits uint, seed int64) (*RandomHyperplaneLSH, error) {
+	if dim < 1 {
+		return nil, errors.New("invalid dimension")
+	}
+
+	if numTables < 1 {
+		return nil, errors.New("invalid number of tables")
+	}
+
+	if numBits < 1 || numBits > 64 {
+		return nil, errors.New("invalid number of bits, must be between 1 and 64")
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	planes := make([][]float32, numTables)
+	tables := make([]map[uint64][]uint64, numTables)
+	for t := range planes {
+		planes[t] = make([]float32, numBits*dim)
+		for i := range planes[t] {
+			planes[t][i] = float32(rng.NormFloat64())
+		}
+		tables[t] = make(map[uint64][]uint64)
+	}
+
+	return &RandomHyperplaneLSH{
+		dim:       dim,
+		numTables: numTables,
+		numBits:   numBits,
+		seed:      seed,
+		planes:    planes,
+		tables:    tables,
+	}, nil
+}
+
+// project returns the dot products of the vector with every hyperplane of a table
+func (l *RandomHyperplaneLSH) project(table uint, vector []float32, projections []float32) {
+	planes := l.planes[table]
+	for b := uint(0); b < l.numBits; b++ {
+		plane := planes[b*l.dim : (b+1)*l.dim]
+		sum := float32(0)
+		for i, x := range vector {
+			sum += plane[i] * x
+		}
+		projections[b] = sum
+	}
+}
+
+func signatureOf(projections []float32) uint64 {
+	signature := uint64(0)
+	for b, p := range projections {
+		if p >= 0 {
+			signature |= 1 << uint(b)
+		}
+	}
+	return signature
+}
+
+// Signature returns the signature of the vector in the given table
+func (l *RandomHyperplaneLSH) Signature(table uint, vector []float32) (uint64, error) {
+	if err := l.validateVector(vector); err != nil {
+		return 0, err
+	}
+
+	if table >= l.numTables {
+		return 0, errors.New("table index out of range")
+	}
+
+	projections := make([]float32, l.numBits)
+	l.project(table, vector, projections)
+	return signatureOf(projections), nil
+}
+
+// Insert adds a vector with the given id to every table
+func (l *RandomHyperplaneLSH) Insert(id uint64, vector []float32) error {
+	if err := l.validateVector(vector); err != nil {
+		return err
+	}
+
+	projections := make([]float32, l.numBits)
+	for t := range l.numTables {
+		l.project(t, vector, projections)
+		signature := signatureOf(projections)
+		l.tables[t][signature] = append(l.tables[t][signature], id)
+	}
+	l.count++
+
+	return nil
+}
+
+// Query returns the ids of candidate neighbors of the vector
+// probes: number of buckets examined per table, 1 checks only the exact signature
+// and larger values also check the buckets most likely to hold near neighbors
+func (l *RandomHyperplaneLSH) Query(vector []float32, probes uint) ([]uint64, error) {
+	if err := l.validateVector(vector); err != nil {
+		return nil, err
+	}
+
+	if probes < 1 {
+		return nil, errors.New("number of probes must be at least 1")
+	}
+
+	seen := make(map[uint64]struct{})
+	candidates := make([]uint64, 0)
+	projections := make([]float32, l.numBits)
+	for t := range l.numTables {
+		l.project(t, vector, projections)
+		for _, signature := range probeSequence(projections, probes) {
+			for _, id := range l.tables[t][signature] {
+				if _, ok := seen[id]; ok {
+					continue
+				}
+				seen[id] = struct{}{}
+				candidates = append(candidates, id)
+			}
+		}
+	}
+
+	return candidates, nil
+}
+
+// Count returns the number of inserted vectors
+func (l *RandomHyperplaneLSH) Count() uint {
+	return l.count
+}
+
+// Seed returns the seed used to generate the hyperplanes
+func (l *RandomHyperplaneLSH) Seed() int64 {
+	return l.seed
+}
+
+func (l *RandomHyperplaneLSH) validateVector(vector []float32) error {
+	if uint(len(vector)) != l.dim {
+		return errors.New("vector dimension mismatch")
+	}
+
+	return nil
+}
+
+// perturbation is a set of bit positions (indices into the margin order) to flip
+type perturbation struct {
+	positions []int
+	score     float64
+}
+
+type perturbationHeap []perturbation
+
+func (h perturbationHeap) Len() int           { return len(h) }
+func (h perturbationHeap) Less(i, j int) bool { return h[i].score < h[j].score }
+func (h perturbationHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *perturbationHeap) Push(x any)        { *h = append(*h, x.(perturbation)) }
+func (h *perturbationHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// probeSequence returns up to probes signatures ordered by how likely they are to
+// contain near neighbors. Bits whose projection is closest to zero are flipped
+// first, following the shift/expand generation of multi-probe LSH (Lv et al.)
+func probeSequence(projections []float32, probes uint) []uint64 {
+	base := signatureOf(projections)
+	signatures := []uint64{base}
+	if probes == 1 {
+		return signatures
+	}
+
+	order := make([]int, len(projections))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return math.Abs(float64(projections[order[a]])) < math.Abs(float64(projections[order[b]]))
+	})
+	margin := func(pos int) float64 {
+		p := float64(projections[order[pos]])
+		return p * p
+	}
+
+	h := &perturbationHeap{{positions: []int{0}, score: margin(0)}}
+	for uint(len(signatures)) < probes && h.Len() > 0 {
+		current := heap.Pop(h).(perturbation)
+
+		signature := base
+		for _, pos := range current.positions {
+			signature ^= 1 << uint(order[pos])
+		}
+		signatures = append(signatures, signature)
+
+		last := current.positions[len(current.positions)-1]
+		if last+1 >= len(order) {
+			continue
+		}
+
+		shifted := append([]int(nil), current.positions...)
+		shifted[len(shifted)-1] = last + 1
+		heap.Push(h, perturbation{positions: shifted, score: current.score - margin(last) + margin(last+1)})
+
+		expanded := append(append([]int(nil), current.positions...), last+1)
+		heap.Push(h, perturbation{positions: expanded, score: current.score + margin(last+1)})
+	}
+
+	return signatures
+}
+
+// CosineSimilarity returns the cosine of the angle between two vectors of equal length
+func CosineSimilarity(a, b []float32) (float32, error) {
+	if len(a) != len(b) {
+		return 0, errors.New("vector dimension mismatch")
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0, errors.New("vector norm cannot be zero")
+	}
+
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB))), nil
+}
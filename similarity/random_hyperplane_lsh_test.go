@@ -0,0 +1,240 @@
+package similarity
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestNewRandomHyperplaneLSH(t *testing.T) {
+	tests := []struct {
+		name      string
+		dim       uint
+		numTables uint
+		numBits   uint
+		wantErr   bool
+	}{
+		{
+			name:      "valid parameters",
+			dim:       128,
+			numTables: 8,
+			numBits:   16,
+			wantErr:   false,
+		},
+		{
+			name:      "zero dimension",
+			dim:       0,
+			numTables: 8,
+			numBits:   16,
+			wantErr:   true,
+		},
+		{
+			name:      "zero tables",
+			dim:       128,
+			numTables: 0,
+			numBits:   16,
+			wantErr:   true,
+		},
+		{
+			name:      "too many bits",
+			dim:       128,
+			numTables: 8,
+			numBits:   65,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRandomHyperplaneLSH(tt.dim, tt.numTables, tt.numBits, 42)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewRandomHyperplaneLSH() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRandomHyperplaneLSH_DeterministicSeed(t *testing.T) {
+	lsh1, err := NewRandomHyperplaneLSH(32, 4, 16, 7)
+	if err != nil {
+		t.Fatalf("Failed to create LSH: %v", err)
+	}
+	lsh2, err := NewRandomHyperplaneLSH(32, 4, 16, 7)
+	if err != nil {
+		t.Fatalf("Failed to create LSH: %v", err)
+	}
+
+	vector := randomVector(rand.New(rand.NewSource(1)), 32)
+	for table := uint(0); table < 4; table++ {
+		sig1, err := lsh1.Signature(table, vector)
+		if err != nil {
+			t.Fatalf("Signature() error = %v", err)
+		}
+		sig2, err := lsh2.Signature(table, vector)
+		if err != nil {
+			t.Fatalf("Signature() error = %v", err)
+		}
+		if sig1 != sig2 {
+			t.Errorf("Signature() differs for identical seeds in table %d: %x != %x", table, sig1, sig2)
+		}
+	}
+}
+
+func TestRandomHyperplaneLSH_InvalidInput(t *testing.T) {
+	lsh, err := NewRandomHyperplaneLSH(8, 2, 8, 1)
+	if err != nil {
+		t.Fatalf("Failed to create LSH: %v", err)
+	}
+
+	if err := lsh.Insert(1, make([]float32, 4)); err == nil {
+		t.Error("Insert() with wrong dimension should return error")
+	}
+
+	if _, err := lsh.Query(make([]float32, 8), 0); err == nil {
+		t.Error("Query() with zero probes should return error")
+	}
+
+	if _, err := lsh.Signature(2, make([]float32, 8)); err == nil {
+		t.Error("Signature() with out of range table should return error")
+	}
+}
+
+func TestRandomHyperplaneLSH_RecallPrecision(t *testing.T) {
+	const (
+		dim       = 64
+		numPoints = 2000
+		numQuery  = 200
+		threshold = 0.9
+	)
+
+	rng := rand.New(rand.NewSource(2024))
+	lsh, err := NewRandomHyperplaneLSH(dim, 6, 16, 99)
+	if err != nil {
+		t.Fatalf("Failed to create LSH: %v", err)
+	}
+
+	points := make([][]float32, numPoints)
+	for i := range points {
+		points[i] = randomVector(rng, dim)
+		if err := lsh.Insert(uint64(i), points[i]); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	// Each query is a small perturbation of a random data point
+	queries := make([][]float32, numQuery)
+	for i := range queries {
+		base := points[rng.Intn(numPoints)]
+		queries[i] = make([]float32, dim)
+		for j := range base {
+			queries[i][j] = base[j] + float32(rng.NormFloat64()*0.4)
+		}
+	}
+
+	evaluate := func(probes uint) (float64, float64) {
+		relevant, found, candidates := 0, 0, 0
+		for _, q := range queries {
+			result, err := lsh.Query(q, probes)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			returned := make(map[uint64]struct{}, len(result))
+			for _, id := range result {
+				returned[id] = struct{}{}
+			}
+			candidates += len(result)
+
+			for i, p := range points {
+				sim, _ := CosineSimilarity(q, p)
+				if sim < threshold {
+					continue
+				}
+				relevant++
+				if _, ok := returned[uint64(i)]; ok {
+					found++
+				}
+			}
+		}
+		return float64(found) / float64(relevant), float64(found) / float64(candidates)
+	}
+
+	recall1, precision1 := evaluate(1)
+	recall8, precision8 := evaluate(8)
+	t.Logf("probes=1 recall=%.3f precision=%.3f", recall1, precision1)
+	t.Logf("probes=8 recall=%.3f precision=%.3f", recall8, precision8)
+
+	if recall8 < 0.95 {
+		t.Errorf("Recall with multi-probe too low: got %.3f, want >= 0.95", recall8)
+	}
+
+	if recall8 <= recall1 {
+		t.Errorf("Multi-probe recall %.3f not higher than single probe recall %.3f", recall8, recall1)
+	}
+
+	if precision1 < 0.05 {
+		t.Errorf("Precision with single probe too low: got %.3f, want >= 0.05", precision1)
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        []float32
+		b        []float32
+		expected float32
+		wantErr  bool
+	}{
+		{
+			name:     "identical vectors",
+			a:        []float32{1, 2, 3},
+			b:        []float32{1, 2, 3},
+			expected: 1,
+		},
+		{
+			name:     "orthogonal vectors",
+			a:        []float32{1, 0},
+			b:        []float32{0, 1},
+			expected: 0,
+		},
+		{
+			name:     "opposite vectors",
+			a:        []float32{1, 1},
+			b:        []float32{-1, -1},
+			expected: -1,
+		},
+		{
+			name:    "dimension mismatch",
+			a:       []float32{1, 1},
+			b:       []float32{1},
+			wantErr: true,
+		},
+		{
+			name:    "zero vector",
+			a:       []float32{0, 0},
+			b:       []float32{1, 1},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CosineSimilarity(tt.a, tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CosineSimilarity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := got - tt.expected; diff > 1e-6 || diff < -1e-6 {
+				t.Errorf("CosineSimilarity() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func randomVector(rng *rand.Rand, dim int) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = float32(rng.NormFloat64())
+	}
+	return v
+}
@@ -16,6 +16,11 @@ func (m *Murmur3) Hash(data []byte) uint32 {
 	return Murmur3_32(data, m.seed)
 }
 
+// Seed returns the seed of the hash function
+func (m *Murmur3) Seed() uint32 {
+	return m.seed
+}
+
 func murmur32Scramble(k uint32) uint32 {
 	k *= 0xcc9e2d51
 	k = (k << 15) | (k >> (17))
@@ -0,0 +1,79 @@
+// SipHash-2-4 as described in "SipHash: a fast short-input PRF" by
+// Jean-Philippe Aumasson and Daniel J. Bernstein
+// https://www.aumasson.jp/siphash/siphash.pdf
+package utils
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+type SipHash struct {
+	k0 uint64
+	k1 uint64
+}
+
+// NewSipHash creates a SipHash-2-4 hasher from a 128-bit key, read as two
+// little-endian 64-bit words
+func NewSipHash(key [16]byte) *SipHash {
+	return &SipHash{
+		k0: binary.LittleEndian.Uint64(key[0:8]),
+		k1: binary.LittleEndian.Uint64(key[8:16]),
+	}
+}
+
+func (s *SipHash) Hash(data []byte) uint64 {
+	return SipHash24(s.k0, s.k1, data)
+}
+
+func sipRound(v0, v1, v2, v3 uint64) (uint64, uint64, uint64, uint64) {
+	v0 += v1
+	v1 = bits.RotateLeft64(v1, 13)
+	v1 ^= v0
+	v0 = bits.RotateLeft64(v0, 32)
+	v2 += v3
+	v3 = bits.RotateLeft64(v3, 16)
+	v3 ^= v2
+	v0 += v3
+	v3 = bits.RotateLeft64(v3, 21)
+	v3 ^= v0
+	v2 += v1
+	v1 = bits.RotateLeft64(v1, 17)
+	v1 ^= v2
+	v2 = bits.RotateLeft64(v2, 32)
+	return v0, v1, v2, v3
+}
+
+func SipHash24(k0, k1 uint64, data []byte) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+
+	length := len(data)
+	for len(data) >= 8 {
+		m := binary.LittleEndian.Uint64(data)
+		v3 ^= m
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0 ^= m
+		data = data[8:]
+	}
+
+	// The last block holds the remaining bytes and the message length in the top byte
+	m := uint64(length) << 56
+	for i, b := range data {
+		m |= uint64(b) << (8 * i)
+	}
+	v3 ^= m
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0 ^= m
+
+	v2 ^= 0xff
+	for range 4 {
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	}
+
+	return v0 ^ v1 ^ v2 ^ v3
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"testing"
+)
+
+// Reference vectors from the SipHash paper: the key is the bytes 00..0f and the
+// message of length n is the bytes 00..n-1
+func TestSipHash24(t *testing.T) {
+	var key [16]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+	hasher := NewSipHash(key)
+
+	tests := []struct {
+		name     string
+		length   int
+		expected uint64
+	}{
+		{
+			name:     "Empty message",
+			length:   0,
+			expected: 0x726fdb47dd0e0e31,
+		},
+		{
+			name:     "One byte",
+			length:   1,
+			expected: 0x74f839c593dc67fd,
+		},
+		{
+			name:     "Fifteen bytes",
+			length:   15,
+			expected: 0xa129ca6149be45e5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := make([]byte, tt.length)
+			for i := range message {
+				message[i] = byte(i)
+			}
+
+			if result := hasher.Hash(message); result != tt.expected {
+				t.Errorf("SipHash24() = %#016x, want %#016x", result, tt.expected)
+			}
+		})
+	}
+}